@@ -9,14 +9,25 @@ namespace Services.Core.Helpers
     public class AsyncLogger : IAsyncDisposable, IDisposable
     {
         private readonly string _logPath;
+        private readonly long _maxSizeBytes;
+        private readonly int _maxFiles;
         private readonly BlockingCollection<string> _logQueue = new BlockingCollection<string>();
         private readonly CancellationTokenSource _cts = new CancellationTokenSource();
         private readonly Task _writeTask;
         private bool _disposed;
 
-        public AsyncLogger(string logPath)
+        // Queued by RotateNow so the rollover happens on the writer thread, after earlier lines
+        private static readonly string RotateMarker = new('\0', 1);
+
+        // A reader without FileShare.Delete blocks the move; keep writing and try again later
+        private static readonly TimeSpan RotationRetryInterval = TimeSpan.FromSeconds(30);
+        private DateTime _nextRotationAttempt = DateTime.MinValue;
+
+        public AsyncLogger(string logPath, long maxSizeBytes = 0, int maxFiles = 1)
         {
             _logPath = logPath;
+            _maxSizeBytes = maxSizeBytes;
+            _maxFiles = Math.Max(maxFiles, 1);
             _writeTask = Task.Run(ProcessQueue);
         }
 
@@ -34,16 +45,44 @@ namespace Services.Core.Helpers
             }
         }
 
+        public void RotateNow()
+        {
+            if (!_cts.IsCancellationRequested && !_disposed)
+            {
+                try
+                {
+                    _logQueue.Add(RotateMarker);
+                }
+                catch (InvalidOperationException)
+                {
+                }
+            }
+        }
+
         private void ProcessQueue()
         {
+            StreamWriter? writer = null;
             try
             {
-                using (var fs = new FileStream(_logPath, FileMode.Append, FileAccess.Write, FileShare.Read))
-                using (var writer = new StreamWriter(fs) { AutoFlush = true })
+                // An appended-to log may already be over the limit from a previous run
+                string? rotationError = null;
+                if (_maxSizeBytes > 0 && File.Exists(_logPath) && new FileInfo(_logPath).Length >= _maxSizeBytes)
+                    TryRollOver(out rotationError);
+
+                writer = OpenWriter();
+                if (rotationError != null) writer.WriteLine(FormatRotationError(rotationError));
+
+                foreach (var line in _logQueue.GetConsumingEnumerable(_cts.Token))
                 {
-                    foreach (var line in _logQueue.GetConsumingEnumerable(_cts.Token))
+                    bool rotate = ReferenceEquals(line, RotateMarker);
+                    if (!rotate) writer.WriteLine(line);
+
+                    if (rotate || (_maxSizeBytes > 0 && writer.BaseStream.Length >= _maxSizeBytes && DateTime.Now >= _nextRotationAttempt))
                     {
-                        writer.WriteLine(line);
+                        writer.Dispose();
+                        bool rotated = TryRollOver(out rotationError);
+                        writer = OpenWriter();
+                        if (!rotated) writer.WriteLine(FormatRotationError(rotationError!));
                     }
                 }
             }
@@ -54,6 +93,46 @@ namespace Services.Core.Helpers
             {
                 System.Diagnostics.Debug.WriteLine($"AsyncLogger ProcessQueue error: {ex.Message}");
             }
+            finally
+            {
+                writer?.Dispose();
+            }
+        }
+
+        private StreamWriter OpenWriter()
+        {
+            var fs = new FileStream(_logPath, FileMode.Append, FileAccess.Write, FileShare.Read);
+            return new StreamWriter(fs) { AutoFlush = true };
+        }
+
+        // Shifts previous generations up (<name>.1.log is the newest) and drops the oldest.
+        // On failure the current file stays in place and keeps growing until the next attempt.
+        private bool TryRollOver(out string? error)
+        {
+            error = null;
+            try
+            {
+                for (int i = _maxFiles - 1; i >= 1; i--)
+                {
+                    var older = Path.ChangeExtension(_logPath, $".{i}.log");
+                    if (File.Exists(older)) File.Move(older, Path.ChangeExtension(_logPath, $".{i + 1}.log"), true);
+                }
+                File.Move(_logPath, Path.ChangeExtension(_logPath, ".1.log"), true);
+                _nextRotationAttempt = DateTime.MinValue;
+                return true;
+            }
+            catch (Exception ex) when (ex is IOException || ex is UnauthorizedAccessException)
+            {
+                System.Diagnostics.Debug.WriteLine($"AsyncLogger rotation failed: {ex.Message}");
+                _nextRotationAttempt = DateTime.Now + RotationRetryInterval;
+                error = ex.Message;
+                return false;
+            }
+        }
+
+        private static string FormatRotationError(string error)
+        {
+            return $"[{DateTime.Now:HH:mm:ss}] Log rotation failed, retrying in {RotationRetryInterval.TotalSeconds:0}s: {error}";
         }
 
         public async ValueTask DisposeAsync()
@@ -0,0 +1,31 @@
+using System;
+using System.IO;
+using System.Security.Principal;
+
+namespace Services.Core.Helpers
+{
+    // Append-only record of administrative operations, kept outside the rotated service logs
+    public static class AuditLog
+    {
+        private static readonly string AuditFile = Path.Combine(
+            Environment.GetFolderPath(Environment.SpecialFolder.CommonApplicationData), "WindowsServiceManager", "audit.log");
+        private static readonly object FileLock = new();
+
+        public static void Write(string operation, string target, string details)
+        {
+            try
+            {
+                var line = $"[{DateTime.Now:yyyy-MM-dd HH:mm:ss}] {WindowsIdentity.GetCurrent().Name} {operation} {target}: {details}";
+                lock (FileLock)
+                {
+                    Directory.CreateDirectory(Path.GetDirectoryName(AuditFile)!);
+                    File.AppendAllText(AuditFile, line + Environment.NewLine);
+                }
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to write audit log: {ex.Message}");
+            }
+        }
+    }
+}
@@ -0,0 +1,81 @@
+using System;
+using System.Collections.Generic;
+using System.Runtime.InteropServices;
+using System.Security.Cryptography.X509Certificates;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    public static class CertificateUtils
+    {
+        private static readonly IntPtr CERT_STORE_PROV_SYSTEM_W = new(10);
+        private const uint CERT_SYSTEM_STORE_LOCAL_MACHINE = 2 << 16;
+        private const uint CERT_SYSTEM_STORE_USERS = 6 << 16;
+        private const uint CERT_STORE_READONLY_FLAG = 0x00008000;
+        private const uint CERT_STORE_OPEN_EXISTING_FLAG = 0x00004000;
+        private const int ERROR_FILE_NOT_FOUND = 2;
+
+        [DllImport("crypt32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        private static extern IntPtr CertOpenStore(IntPtr lpszStoreProvider, uint dwEncodingType, IntPtr hCryptProv, uint dwFlags, string pvPara);
+
+        [DllImport("crypt32.dll", SetLastError = true)]
+        private static extern IntPtr CertEnumCertificatesInStore(IntPtr hCertStore, IntPtr pPrevCertContext);
+
+        [DllImport("crypt32.dll", SetLastError = true)]
+        private static extern bool CertCloseStore(IntPtr hCertStore, uint dwFlags);
+
+        // Machine-wide store, e.g. LocalMachine\MY where server certificates are usually installed
+        public static List<CertificateInfo> GetLocalMachineCertificates(string storeName)
+        {
+            return GetStoreCertificates(CERT_SYSTEM_STORE_LOCAL_MACHINE, storeName, $@"LocalMachine\{storeName}");
+        }
+
+        // The account's CurrentUser store as mounted under HKEY_USERS\<SID>. An unloaded profile or
+        // a store that was never created yields no certificates.
+        public static List<CertificateInfo> GetUserCertificates(string sid, string storeName)
+        {
+            return GetStoreCertificates(CERT_SYSTEM_STORE_USERS, $@"{sid}\{storeName}", $@"{sid}\{storeName}");
+        }
+
+        private static List<CertificateInfo> GetStoreCertificates(uint location, string storePath, string label)
+        {
+            var certificates = new List<CertificateInfo>();
+
+            IntPtr hStore = CertOpenStore(CERT_STORE_PROV_SYSTEM_W, 0, IntPtr.Zero,
+                location | CERT_STORE_READONLY_FLAG | CERT_STORE_OPEN_EXISTING_FLAG, storePath);
+            if (hStore == IntPtr.Zero)
+            {
+                if (Marshal.GetLastWin32Error() == ERROR_FILE_NOT_FOUND) return certificates;
+                throw ServiceOperationException.FromLastError($"Failed to open certificate store {label}");
+            }
+
+            try
+            {
+                // Each call frees the previous context, so the wrapper below takes its own copy
+                IntPtr context = IntPtr.Zero;
+                while ((context = CertEnumCertificatesInStore(hStore, context)) != IntPtr.Zero)
+                {
+                    using var cert = new X509Certificate2(context);
+                    int daysLeft = (int)Math.Floor((cert.NotAfter - DateTime.Now).TotalDays);
+                    certificates.Add(new CertificateInfo
+                    {
+                        Store = label,
+                        SubjectName = cert.Subject,
+                        Issuer = cert.Issuer,
+                        ValidFrom = cert.NotBefore,
+                        ValidTo = cert.NotAfter,
+                        IsExpired = DateTime.Now > cert.NotAfter,
+                        DaysUntilExpiry = daysLeft,
+                        Thumbprint = cert.Thumbprint
+                    });
+                }
+            }
+            finally
+            {
+                CertCloseStore(hStore, 0);
+            }
+
+            return certificates;
+        }
+    }
+}
@@ -0,0 +1,93 @@
+using System;
+using System.Runtime.InteropServices;
+using System.Text;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    public static class CredentialUtils
+    {
+        private const uint CRED_TYPE_GENERIC = 1;
+        private const uint CRED_PERSIST_LOCAL_MACHINE = 2;
+
+        [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+        private struct CREDENTIAL
+        {
+            public uint Flags;
+            public uint Type;
+            public string TargetName;
+            public string? Comment;
+            public long LastWritten;
+            public uint CredentialBlobSize;
+            public IntPtr CredentialBlob;
+            public uint Persist;
+            public uint AttributeCount;
+            public IntPtr Attributes;
+            public string? TargetAlias;
+            public string UserName;
+        }
+
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode, EntryPoint = "CredWriteW")]
+        private static extern bool CredWrite(ref CREDENTIAL credential, uint flags);
+
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode, EntryPoint = "CredReadW")]
+        private static extern bool CredRead(string target, uint type, uint flags, out IntPtr credential);
+
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode, EntryPoint = "CredDeleteW")]
+        private static extern bool CredDelete(string target, uint type, uint flags);
+
+        [DllImport("advapi32.dll")]
+        private static extern void CredFree(IntPtr buffer);
+
+        public static void Write(string targetName, string username, string password)
+        {
+            var blob = Encoding.Unicode.GetBytes(password);
+            var blobPtr = Marshal.AllocHGlobal(blob.Length);
+            try
+            {
+                Marshal.Copy(blob, 0, blobPtr, blob.Length);
+                var credential = new CREDENTIAL
+                {
+                    Type = CRED_TYPE_GENERIC,
+                    TargetName = targetName,
+                    UserName = username,
+                    CredentialBlob = blobPtr,
+                    CredentialBlobSize = (uint)blob.Length,
+                    Persist = CRED_PERSIST_LOCAL_MACHINE
+                };
+
+                if (!CredWrite(ref credential, 0))
+                    throw ServiceOperationException.FromLastError($"Failed to store credential {targetName}");
+            }
+            finally
+            {
+                Marshal.FreeHGlobal(blobPtr);
+            }
+        }
+
+        public static (string Username, string Password) Read(string targetName)
+        {
+            if (!CredRead(targetName, CRED_TYPE_GENERIC, 0, out var credPtr))
+                throw ServiceOperationException.FromLastError($"Failed to read credential {targetName}");
+
+            try
+            {
+                var credential = Marshal.PtrToStructure<CREDENTIAL>(credPtr);
+                var password = credential.CredentialBlobSize > 0
+                    ? Marshal.PtrToStringUni(credential.CredentialBlob, (int)credential.CredentialBlobSize / 2)
+                    : string.Empty;
+                return (credential.UserName ?? string.Empty, password);
+            }
+            finally
+            {
+                CredFree(credPtr);
+            }
+        }
+
+        public static void Delete(string targetName)
+        {
+            if (!CredDelete(targetName, CRED_TYPE_GENERIC, 0))
+                throw ServiceOperationException.FromLastError($"Failed to delete credential {targetName}");
+        }
+    }
+}
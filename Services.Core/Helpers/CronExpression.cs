@@ -0,0 +1,86 @@
+using System;
+using System.Collections.Generic;
+
+namespace Services.Core.Helpers
+{
+    // Minimal cron parser: "minute hour day [month [weekday]]" with *, lists, ranges and steps
+    public sealed class CronExpression
+    {
+        private readonly HashSet<int> _minutes;
+        private readonly HashSet<int> _hours;
+        private readonly HashSet<int> _days;
+        private readonly HashSet<int> _months;
+        private readonly HashSet<int> _weekdays;
+
+        private CronExpression(HashSet<int> minutes, HashSet<int> hours, HashSet<int> days, HashSet<int> months, HashSet<int> weekdays)
+        {
+            _minutes = minutes;
+            _hours = hours;
+            _days = days;
+            _months = months;
+            _weekdays = weekdays;
+        }
+
+        public static CronExpression Parse(string expression)
+        {
+            var fields = expression.Split(' ', StringSplitOptions.RemoveEmptyEntries);
+            if (fields.Length < 3 || fields.Length > 5)
+                throw new FormatException($"Cron expression must have 3 to 5 fields: {expression}");
+
+            return new CronExpression(
+                ParseField(fields[0], 0, 59),
+                ParseField(fields[1], 0, 23),
+                ParseField(fields[2], 1, 31),
+                ParseField(fields.Length > 3 ? fields[3] : "*", 1, 12),
+                ParseField(fields.Length > 4 ? fields[4] : "*", 0, 6));
+        }
+
+        public bool Matches(DateTime time)
+        {
+            return _minutes.Contains(time.Minute) &&
+                   _hours.Contains(time.Hour) &&
+                   _days.Contains(time.Day) &&
+                   _months.Contains(time.Month) &&
+                   _weekdays.Contains((int)time.DayOfWeek);
+        }
+
+        private static HashSet<int> ParseField(string field, int min, int max)
+        {
+            var values = new HashSet<int>();
+            foreach (var part in field.Split(','))
+            {
+                var rangeAndStep = part.Split('/');
+                if (rangeAndStep.Length > 2) throw new FormatException($"Invalid cron field: {field}");
+
+                int step = 1;
+                if (rangeAndStep.Length == 2 && (!int.TryParse(rangeAndStep[1], out step) || step <= 0))
+                    throw new FormatException($"Invalid cron step: {part}");
+
+                int start, end;
+                if (rangeAndStep[0] == "*")
+                {
+                    start = min;
+                    end = max;
+                }
+                else if (rangeAndStep[0].Contains('-'))
+                {
+                    var bounds = rangeAndStep[0].Split('-');
+                    if (bounds.Length != 2 || !int.TryParse(bounds[0], out start) || !int.TryParse(bounds[1], out end))
+                        throw new FormatException($"Invalid cron range: {part}");
+                }
+                else
+                {
+                    if (!int.TryParse(rangeAndStep[0], out start))
+                        throw new FormatException($"Invalid cron value: {part}");
+                    end = rangeAndStep.Length == 2 ? max : start;
+                }
+
+                if (start < min || end > max || start > end)
+                    throw new FormatException($"Cron value out of range {min}-{max}: {part}");
+
+                for (int v = start; v <= end; v += step) values.Add(v);
+            }
+            return values;
+        }
+    }
+}
@@ -0,0 +1,220 @@
+using System;
+using System.Collections.Generic;
+using System.Runtime.InteropServices;
+using System.Threading;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    // Minimal real-time ETW consumer for a single manifest provider. Struct layouts are the x64 ones;
+    // fields are written and read by offset so the nested Win32 structures need not be declared.
+    public static class EtwTrace
+    {
+        public static readonly Guid KernelRegistryProvider = new("70EB4F03-C1DE-4F73-A051-33D13D5413BD");
+        public static readonly Guid KernelFileProvider = new("EDD08927-9CC4-4E65-B970-C2560FB5C289");
+
+        private const uint WNODE_FLAG_TRACED_GUID = 0x00020000;
+        private const uint EVENT_TRACE_REAL_TIME_MODE = 0x00000100;
+        private const uint EVENT_TRACE_CONTROL_STOP = 1;
+        private const uint EVENT_CONTROL_CODE_ENABLE_PROVIDER = 1;
+        private const byte TRACE_LEVEL_VERBOSE = 5;
+        private const uint PROCESS_TRACE_MODE_REAL_TIME = 0x00000100;
+        private const uint PROCESS_TRACE_MODE_EVENT_RECORD = 0x10000000;
+        private const int ERROR_ALREADY_EXISTS = 183;
+        private static readonly long INVALID_PROCESSTRACE_HANDLE = -1;
+
+        private const int PropertiesSize = 120;
+        private const int LogFileSize = 448;
+
+        [StructLayout(LayoutKind.Sequential)]
+        private struct PROPERTY_DATA_DESCRIPTOR
+        {
+            public IntPtr PropertyName;
+            public uint ArrayIndex;
+            public uint Reserved;
+        }
+
+        [UnmanagedFunctionPointer(CallingConvention.StdCall)]
+        private delegate void EventRecordCallback(IntPtr eventRecord);
+
+        [DllImport("advapi32.dll", CharSet = CharSet.Unicode)]
+        private static extern uint StartTrace(out long sessionHandle, string sessionName, IntPtr properties);
+
+        [DllImport("advapi32.dll", CharSet = CharSet.Unicode)]
+        private static extern uint ControlTrace(long sessionHandle, string? sessionName, IntPtr properties, uint controlCode);
+
+        [DllImport("advapi32.dll")]
+        private static extern uint EnableTraceEx2(long sessionHandle, ref Guid providerId, uint controlCode, byte level,
+            ulong matchAnyKeyword, ulong matchAllKeyword, uint timeout, IntPtr enableParameters);
+
+        [DllImport("advapi32.dll", SetLastError = true)]
+        private static extern long OpenTrace(IntPtr logfile);
+
+        [DllImport("advapi32.dll")]
+        private static extern uint ProcessTrace(long[] handleArray, uint handleCount, IntPtr startTime, IntPtr endTime);
+
+        [DllImport("advapi32.dll")]
+        private static extern uint CloseTrace(long traceHandle);
+
+        [DllImport("tdh.dll")]
+        private static extern uint TdhGetPropertySize(IntPtr eventRecord, uint contextCount, IntPtr context, uint descriptorCount, ref PROPERTY_DATA_DESCRIPTOR descriptor, out uint propertySize);
+
+        [DllImport("tdh.dll")]
+        private static extern uint TdhGetProperty(IntPtr eventRecord, uint contextCount, IntPtr context, uint descriptorCount, ref PROPERTY_DATA_DESCRIPTOR descriptor, uint bufferSize, IntPtr buffer);
+
+        // Decoded view of an EVENT_RECORD, valid only during the callback
+        public readonly struct EtwEvent
+        {
+            private readonly IntPtr _record;
+
+            public EtwEvent(IntPtr record)
+            {
+                _record = record;
+            }
+
+            public int ProcessId => Marshal.ReadInt32(_record, 12);
+            public DateTime Timestamp => DateTime.FromFileTime(Marshal.ReadInt64(_record, 16));
+            public ushort EventId => (ushort)Marshal.ReadInt16(_record, 40);
+
+            public string GetString(string propertyName)
+            {
+                var bytes = GetPropertyBytes(propertyName);
+                return bytes == null ? "" : System.Text.Encoding.Unicode.GetString(bytes).TrimEnd('\0');
+            }
+
+            public ulong GetUInt64(string propertyName)
+            {
+                var bytes = GetPropertyBytes(propertyName);
+                return bytes?.Length switch
+                {
+                    8 => BitConverter.ToUInt64(bytes, 0),
+                    4 => BitConverter.ToUInt32(bytes, 0),
+                    2 => BitConverter.ToUInt16(bytes, 0),
+                    _ => 0
+                };
+            }
+
+            private byte[]? GetPropertyBytes(string propertyName)
+            {
+                IntPtr name = Marshal.StringToHGlobalUni(propertyName);
+                try
+                {
+                    var descriptor = new PROPERTY_DATA_DESCRIPTOR { PropertyName = name, ArrayIndex = uint.MaxValue };
+                    if (TdhGetPropertySize(_record, 0, IntPtr.Zero, 1, ref descriptor, out uint size) != 0 || size == 0) return null;
+
+                    IntPtr buffer = Marshal.AllocHGlobal((int)size);
+                    try
+                    {
+                        if (TdhGetProperty(_record, 0, IntPtr.Zero, 1, ref descriptor, size, buffer) != 0) return null;
+                        var bytes = new byte[size];
+                        Marshal.Copy(buffer, bytes, 0, (int)size);
+                        return bytes;
+                    }
+                    finally
+                    {
+                        Marshal.FreeHGlobal(buffer);
+                    }
+                }
+                finally
+                {
+                    Marshal.FreeHGlobal(name);
+                }
+            }
+        }
+
+        // Runs a private real-time session for the duration and maps events of the given process; `map` returns
+        // null to drop an event. Requires administrator rights.
+        public static List<T> Collect<T>(Guid provider, ulong keywords, int pid, TimeSpan duration, Func<EtwEvent, T?> map) where T : class
+        {
+            var results = new List<T>();
+            string sessionName = $"WSM_Trace_{Environment.ProcessId}_{Guid.NewGuid():N}";
+
+            IntPtr properties = AllocProperties(sessionName);
+            IntPtr logfile = IntPtr.Zero;
+            IntPtr loggerName = IntPtr.Zero;
+            long traceHandle = INVALID_PROCESSTRACE_HANDLE;
+
+            EventRecordCallback callback = record =>
+            {
+                try
+                {
+                    var e = new EtwEvent(record);
+                    if (e.ProcessId != pid) return;
+                    var item = map(e);
+                    if (item == null) return;
+                    lock (results)
+                    {
+                        results.Add(item);
+                    }
+                }
+                catch (Exception ex)
+                {
+                    System.Diagnostics.Debug.WriteLine($"Failed to decode ETW event: {ex.Message}");
+                }
+            };
+
+            uint status = StartTrace(out long sessionHandle, sessionName, properties);
+            if (status != 0)
+            {
+                Marshal.FreeHGlobal(properties);
+                throw new ServiceOperationException(status == ERROR_ALREADY_EXISTS ? "Trace session already exists" : "Failed to start trace session", (int)status);
+            }
+
+            try
+            {
+                var providerId = provider;
+                status = EnableTraceEx2(sessionHandle, ref providerId, EVENT_CONTROL_CODE_ENABLE_PROVIDER, TRACE_LEVEL_VERBOSE, keywords, 0, 0, IntPtr.Zero);
+                if (status != 0) throw new ServiceOperationException("Failed to enable trace provider", (int)status);
+
+                loggerName = Marshal.StringToHGlobalUni(sessionName);
+                logfile = Marshal.AllocHGlobal(LogFileSize);
+                for (int i = 0; i < LogFileSize; i += 8) Marshal.WriteInt64(logfile, i, 0);
+                Marshal.WriteIntPtr(logfile, 8, loggerName);
+                Marshal.WriteInt32(logfile, 28, unchecked((int)(PROCESS_TRACE_MODE_REAL_TIME | PROCESS_TRACE_MODE_EVENT_RECORD)));
+                Marshal.WriteIntPtr(logfile, 424, Marshal.GetFunctionPointerForDelegate(callback));
+
+                traceHandle = OpenTrace(logfile);
+                if (traceHandle == INVALID_PROCESSTRACE_HANDLE)
+                    throw ServiceOperationException.FromLastError("Failed to open trace");
+
+                // ProcessTrace blocks until the session is stopped
+                var consumer = new Thread(() => ProcessTrace(new[] { traceHandle }, 1, IntPtr.Zero, IntPtr.Zero)) { IsBackground = true };
+                consumer.Start();
+                Thread.Sleep(duration);
+
+                ControlTrace(sessionHandle, null, properties, EVENT_TRACE_CONTROL_STOP);
+                sessionHandle = 0;
+                consumer.Join(TimeSpan.FromSeconds(5));
+            }
+            finally
+            {
+                if (sessionHandle != 0) ControlTrace(sessionHandle, null, properties, EVENT_TRACE_CONTROL_STOP);
+                if (traceHandle != INVALID_PROCESSTRACE_HANDLE) CloseTrace(traceHandle);
+                if (logfile != IntPtr.Zero) Marshal.FreeHGlobal(logfile);
+                if (loggerName != IntPtr.Zero) Marshal.FreeHGlobal(loggerName);
+                Marshal.FreeHGlobal(properties);
+                GC.KeepAlive(callback);
+            }
+
+            lock (results)
+            {
+                return new List<T>(results);
+            }
+        }
+
+        // EVENT_TRACE_PROPERTIES followed by the session name
+        private static IntPtr AllocProperties(string sessionName)
+        {
+            int size = PropertiesSize + (sessionName.Length + 1) * 2;
+            IntPtr buffer = Marshal.AllocHGlobal(size);
+            for (int i = 0; i < size; i++) Marshal.WriteByte(buffer, i, 0);
+
+            Marshal.WriteInt32(buffer, 0, size);                                 // Wnode.BufferSize
+            Marshal.WriteInt32(buffer, 40, 1);                                   // Wnode.ClientContext: QPC timestamps
+            Marshal.WriteInt32(buffer, 44, unchecked((int)WNODE_FLAG_TRACED_GUID)); // Wnode.Flags
+            Marshal.WriteInt32(buffer, 64, unchecked((int)EVENT_TRACE_REAL_TIME_MODE)); // LogFileMode
+            Marshal.WriteInt32(buffer, 116, PropertiesSize);                     // LoggerNameOffset
+            return buffer;
+        }
+    }
+}
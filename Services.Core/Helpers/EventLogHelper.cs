@@ -0,0 +1,71 @@
+using System;
+using System.Collections.Generic;
+using System.Diagnostics;
+using System.Text;
+
+namespace Services.Core.Helpers
+{
+    public static class EventLogHelper
+    {
+        // Event IDs are the low 16 bits of InstanceId; the upper bits carry severity/facility
+        public static uint GetEventId(EventLogEntry entry) => (uint)(entry.InstanceId & 0xFFFF);
+
+        // Walks the log newest-first, stopping at entries older than `since` or once `maxEntries` matches are collected
+        public static List<EventLogEntry> ReadEntries(string logName, DateTime since, Func<EventLogEntry, bool> filter, int maxEntries = int.MaxValue)
+        {
+            var results = new List<EventLogEntry>();
+
+            using var log = new EventLog(logName);
+            var entries = log.Entries;
+            for (int i = entries.Count - 1; i >= 0 && results.Count < maxEntries; i--)
+            {
+                EventLogEntry entry;
+                try
+                {
+                    entry = entries[i];
+                }
+                catch (Exception ex)
+                {
+                    // The log may wrap or be cleared while we read it
+                    Debug.WriteLine($"Failed to read event log entry {i}: {ex.Message}");
+                    continue;
+                }
+
+                if (entry.TimeGenerated < since) break;
+                if (filter(entry)) results.Add(entry);
+            }
+
+            return results;
+        }
+
+        public const uint ServiceStateChangeEventId = 7036;
+        public const int SERVICE_RUNNING = 4;
+
+        // 7036 carries "<service name>/<state>" as UTF-16 binary data; the message text itself is localized
+        public static bool TryParseServiceStateChange(EventLogEntry entry, out string serviceName, out int state)
+        {
+            serviceName = string.Empty;
+            state = 0;
+            if (GetEventId(entry) != ServiceStateChangeEventId || entry.Data == null || entry.Data.Length == 0) return false;
+
+            var data = Encoding.Unicode.GetString(entry.Data).TrimEnd('\0');
+            int slash = data.LastIndexOf('/');
+            if (slash <= 0 || !int.TryParse(data[(slash + 1)..], out state)) return false;
+
+            serviceName = data[..slash];
+            return true;
+        }
+
+        public static bool ReferencesService(EventLogEntry entry, params string[] names)
+        {
+            foreach (var s in entry.ReplacementStrings ?? Array.Empty<string>())
+            {
+                foreach (var name in names)
+                {
+                    if (!string.IsNullOrEmpty(name) && string.Equals(s, name, StringComparison.OrdinalIgnoreCase)) return true;
+                }
+            }
+            return false;
+        }
+    }
+}
@@ -0,0 +1,81 @@
+using System;
+using System.Diagnostics;
+using System.Net.Http;
+using System.Net.Sockets;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    public static class HealthCheckHelper
+    {
+        // Runs one probe and returns null when healthy, otherwise the reason it failed
+        public static string? Check(HealthCheckConfig config)
+        {
+            var timeout = TimeSpan.FromSeconds(config.TimeoutSeconds);
+            try
+            {
+                return config.Type switch
+                {
+                    "http" => CheckHttp(config.Target, timeout),
+                    "tcp" => CheckTcp(config.Target, timeout),
+                    "exec" => CheckExec(config.Target, timeout),
+                    _ => $"Unknown health check type: {config.Type}"
+                };
+            }
+            catch (AggregateException ex)
+            {
+                return ex.InnerException?.Message ?? ex.Message;
+            }
+            catch (Exception ex)
+            {
+                return ex.Message;
+            }
+        }
+
+        // Accepts host:port and [ipv6]:port
+        public static bool TryParseHostPort(string target, out string host, out int port)
+        {
+            host = "";
+            port = 0;
+            int colon = target.LastIndexOf(':');
+            if (colon <= 0 || !int.TryParse(target.Substring(colon + 1), out port) || port < 1 || port > 65535) return false;
+            host = target.Substring(0, colon).Trim('[', ']');
+            return host.Length > 0;
+        }
+
+        private static string? CheckHttp(string url, TimeSpan timeout)
+        {
+            using var client = new HttpClient { Timeout = timeout };
+            using var response = client.GetAsync(url, HttpCompletionOption.ResponseHeadersRead).GetAwaiter().GetResult();
+            return response.IsSuccessStatusCode ? null : $"HTTP {(int)response.StatusCode} {response.ReasonPhrase}";
+        }
+
+        private static string? CheckTcp(string target, TimeSpan timeout)
+        {
+            if (!TryParseHostPort(target, out var host, out var port)) return $"Invalid endpoint: {target}";
+
+            using var client = new TcpClient();
+            var connect = client.ConnectAsync(host, port);
+            if (!connect.Wait(timeout)) return $"Connection to {target} timed out";
+            return null;
+        }
+
+        private static string? CheckExec(string command, TimeSpan timeout)
+        {
+            var psi = new ProcessStartInfo("cmd.exe", $"/c {command}")
+            {
+                UseShellExecute = false,
+                CreateNoWindow = true
+            };
+            using var process = Process.Start(psi);
+            if (process == null) return "Failed to start health check command";
+
+            if (!process.WaitForExit((int)timeout.TotalMilliseconds))
+            {
+                try { process.Kill(true); } catch { }
+                return "Health check command timed out";
+            }
+            return process.ExitCode == 0 ? null : $"Health check command exited with code {process.ExitCode}";
+        }
+    }
+}
@@ -0,0 +1,162 @@
+using System;
+using System.Runtime.InteropServices;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    public sealed class JobObject : IDisposable
+    {
+        public const uint JOB_OBJECT_QUERY = 0x0004;
+        private const int JobObjectBasicAccountingInformation = 1;
+        private const int JobObjectExtendedLimitInformation = 9;
+        private const uint JOB_OBJECT_LIMIT_PROCESS_MEMORY = 0x00000100;
+        private const uint JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE = 0x00002000;
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct JOBOBJECT_BASIC_LIMIT_INFORMATION
+        {
+            public long PerProcessUserTimeLimit;
+            public long PerJobUserTimeLimit;
+            public uint LimitFlags;
+            public UIntPtr MinimumWorkingSetSize;
+            public UIntPtr MaximumWorkingSetSize;
+            public uint ActiveProcessLimit;
+            public UIntPtr Affinity;
+            public uint PriorityClass;
+            public uint SchedulingClass;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct IO_COUNTERS
+        {
+            public ulong ReadOperationCount;
+            public ulong WriteOperationCount;
+            public ulong OtherOperationCount;
+            public ulong ReadTransferCount;
+            public ulong WriteTransferCount;
+            public ulong OtherTransferCount;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+        {
+            public JOBOBJECT_BASIC_LIMIT_INFORMATION BasicLimitInformation;
+            public IO_COUNTERS IoInfo;
+            public UIntPtr ProcessMemoryLimit;
+            public UIntPtr JobMemoryLimit;
+            public UIntPtr PeakProcessMemoryUsed;
+            public UIntPtr PeakJobMemoryUsed;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct JOBOBJECT_BASIC_ACCOUNTING_INFORMATION
+        {
+            public long TotalUserTime;
+            public long TotalKernelTime;
+            public long ThisPeriodTotalUserTime;
+            public long ThisPeriodTotalKernelTime;
+            public uint TotalPageFaultCount;
+            public uint TotalProcesses;
+            public uint ActiveProcesses;
+            public uint TotalTerminatedProcesses;
+        }
+
+        [DllImport("kernel32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        private static extern IntPtr CreateJobObject(IntPtr lpJobAttributes, string? lpName);
+
+        [DllImport("kernel32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        public static extern IntPtr OpenJobObject(uint dwDesiredAccess, bool bInheritHandle, string lpName);
+
+        [DllImport("kernel32.dll", SetLastError = true)]
+        private static extern bool SetInformationJobObject(IntPtr hJob, int infoClass, ref JOBOBJECT_EXTENDED_LIMIT_INFORMATION lpInfo, uint cbInfoLength);
+
+        [DllImport("kernel32.dll", SetLastError = true)]
+        private static extern bool QueryInformationJobObject(IntPtr hJob, int infoClass, out JOBOBJECT_BASIC_ACCOUNTING_INFORMATION lpInfo, uint cbInfoLength, IntPtr lpReturnLength);
+
+        [DllImport("kernel32.dll", SetLastError = true)]
+        private static extern bool QueryInformationJobObject(IntPtr hJob, int infoClass, out JOBOBJECT_EXTENDED_LIMIT_INFORMATION lpInfo, uint cbInfoLength, IntPtr lpReturnLength);
+
+        [DllImport("kernel32.dll", SetLastError = true)]
+        private static extern bool AssignProcessToJobObject(IntPtr hJob, IntPtr hProcess);
+
+        private IntPtr _handle;
+
+        // Named so the manager process can open the job for queries
+        public static string GetJobName(string serviceName) => $@"Global\WSM_Job_{serviceName}";
+
+        public JobObject(string name)
+        {
+            _handle = CreateJobObject(IntPtr.Zero, name);
+            if (_handle == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError("Failed to create job object");
+        }
+
+        public void SetLimits(ulong processMemoryLimitBytes)
+        {
+            var info = new JOBOBJECT_EXTENDED_LIMIT_INFORMATION();
+            // Children must not outlive the wrapper
+            info.BasicLimitInformation.LimitFlags = JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE;
+            if (processMemoryLimitBytes > 0)
+            {
+                info.BasicLimitInformation.LimitFlags |= JOB_OBJECT_LIMIT_PROCESS_MEMORY;
+                info.ProcessMemoryLimit = (UIntPtr)processMemoryLimitBytes;
+            }
+
+            if (!SetInformationJobObject(_handle, JobObjectExtendedLimitInformation, ref info, (uint)Marshal.SizeOf<JOBOBJECT_EXTENDED_LIMIT_INFORMATION>()))
+                throw ServiceOperationException.FromLastError("Failed to set job object limits");
+        }
+
+        public void AssignProcess(IntPtr processHandle)
+        {
+            if (!AssignProcessToJobObject(_handle, processHandle))
+                throw ServiceOperationException.FromLastError("Failed to assign process to job object");
+        }
+
+        // Opens the job created by the wrapper; fails when the wrapper is not running
+        public static JobObjectInfo Query(string name)
+        {
+            var handle = OpenJobObject(JOB_OBJECT_QUERY, false, name);
+            if (handle == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError("Failed to open job object");
+
+            try
+            {
+                if (!QueryInformationJobObject(handle, JobObjectBasicAccountingInformation, out JOBOBJECT_BASIC_ACCOUNTING_INFORMATION accounting,
+                        (uint)Marshal.SizeOf<JOBOBJECT_BASIC_ACCOUNTING_INFORMATION>(), IntPtr.Zero))
+                    throw ServiceOperationException.FromLastError("Failed to query job accounting information");
+                if (!QueryInformationJobObject(handle, JobObjectExtendedLimitInformation, out JOBOBJECT_EXTENDED_LIMIT_INFORMATION limits,
+                        (uint)Marshal.SizeOf<JOBOBJECT_EXTENDED_LIMIT_INFORMATION>(), IntPtr.Zero))
+                    throw ServiceOperationException.FromLastError("Failed to query job limit information");
+
+                return new JobObjectInfo
+                {
+                    TotalProcesses = accounting.TotalProcesses,
+                    ActiveProcesses = accounting.ActiveProcesses,
+                    TotalTerminations = accounting.TotalTerminatedProcesses,
+                    PeakMemoryBytes = (ulong)limits.PeakJobMemoryUsed,
+                    // FILETIME units are 100 ns
+                    TotalUserTimeNs = unchecked((ulong)accounting.TotalUserTime) * 100
+                };
+            }
+            finally
+            {
+                ProcessUtils.CloseHandle(handle);
+            }
+        }
+
+        public void Dispose()
+        {
+            if (_handle != IntPtr.Zero)
+            {
+                ProcessUtils.CloseHandle(_handle);
+                _handle = IntPtr.Zero;
+            }
+            GC.SuppressFinalize(this);
+        }
+
+        ~JobObject()
+        {
+            Dispose();
+        }
+    }
+}
@@ -0,0 +1,198 @@
+using System;
+using System.Collections.Generic;
+using System.Runtime.InteropServices;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    public static class NetworkUtils
+    {
+        private const int AF_INET = 2;
+        private const int AF_INET6 = 23;
+        private const int TCP_TABLE_OWNER_PID_ALL = 5;
+        private const int UDP_TABLE_OWNER_PID = 1;
+        private const int TcpConnectionEstatsData = 1;
+        public const uint MIB_TCP_STATE_ESTAB = 5;
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct MIB_TCPROW_OWNER_PID
+        {
+            public uint dwState;
+            public uint dwLocalAddr;
+            public uint dwLocalPort;
+            public uint dwRemoteAddr;
+            public uint dwRemotePort;
+            public uint dwOwningPid;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct MIB_UDPROW_OWNER_PID
+        {
+            public uint dwLocalAddr;
+            public uint dwLocalPort;
+            public uint dwOwningPid;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct MIB_TCP6ROW_OWNER_PID
+        {
+            [MarshalAs(UnmanagedType.ByValArray, SizeConst = 16)]
+            public byte[] ucLocalAddr;
+            public uint dwLocalScopeId;
+            public uint dwLocalPort;
+            [MarshalAs(UnmanagedType.ByValArray, SizeConst = 16)]
+            public byte[] ucRemoteAddr;
+            public uint dwRemoteScopeId;
+            public uint dwRemotePort;
+            public uint dwState;
+            public uint dwOwningPid;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct MIB_UDP6ROW_OWNER_PID
+        {
+            [MarshalAs(UnmanagedType.ByValArray, SizeConst = 16)]
+            public byte[] ucLocalAddr;
+            public uint dwLocalScopeId;
+            public uint dwLocalPort;
+            public uint dwOwningPid;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        private struct MIB_TCPROW
+        {
+            public uint dwState;
+            public uint dwLocalAddr;
+            public uint dwLocalPort;
+            public uint dwRemoteAddr;
+            public uint dwRemotePort;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        private struct TCP_ESTATS_DATA_RW_v0
+        {
+            public byte EnableCollection;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        private struct TCP_ESTATS_DATA_ROD_v0
+        {
+            public ulong DataBytesOut;
+            public ulong DataSegsOut;
+            public ulong DataBytesIn;
+            public ulong DataSegsIn;
+            public ulong SegsOut;
+            public ulong SegsIn;
+            public uint SoftErrors;
+            public uint SoftErrorReason;
+            public uint SndUna;
+            public uint SndNxt;
+            public uint SndMax;
+            public ulong ThruBytesAcked;
+            public uint RcvNxt;
+            public ulong ThruBytesReceived;
+        }
+
+        [DllImport("iphlpapi.dll", SetLastError = true)]
+        private static extern uint GetExtendedTcpTable(IntPtr pTcpTable, ref int dwOutBufLen, bool sort, int ipVersion, int tblClass, uint reserved);
+
+        [DllImport("iphlpapi.dll", SetLastError = true)]
+        private static extern uint GetExtendedUdpTable(IntPtr pUdpTable, ref int dwOutBufLen, bool sort, int ipVersion, int tblClass, uint reserved);
+
+        [DllImport("iphlpapi.dll")]
+        private static extern uint SetPerTcpConnectionEStats(ref MIB_TCPROW row, int estatsType, ref TCP_ESTATS_DATA_RW_v0 rw, uint rwVersion, uint rwSize, uint offset);
+
+        [DllImport("iphlpapi.dll")]
+        private static extern uint GetPerTcpConnectionEStats(ref MIB_TCPROW row, int estatsType, IntPtr rw, uint rwVersion, uint rwSize,
+            IntPtr ros, uint rosVersion, uint rosSize, out TCP_ESTATS_DATA_ROD_v0 rod, uint rodVersion, uint rodSize);
+
+        // IPv4 TCP connections of all processes
+        public static List<MIB_TCPROW_OWNER_PID> GetTcpConnections()
+        {
+            return ReadTable<MIB_TCPROW_OWNER_PID>(true, AF_INET);
+        }
+
+        // IPv6 TCP connections of all processes
+        public static List<MIB_TCP6ROW_OWNER_PID> GetTcp6Connections()
+        {
+            return ReadTable<MIB_TCP6ROW_OWNER_PID>(true, AF_INET6);
+        }
+
+        // IPv4 UDP endpoints of all processes
+        public static List<MIB_UDPROW_OWNER_PID> GetUdpEndpoints()
+        {
+            return ReadTable<MIB_UDPROW_OWNER_PID>(false, AF_INET);
+        }
+
+        // IPv6 UDP endpoints of all processes
+        public static List<MIB_UDP6ROW_OWNER_PID> GetUdp6Endpoints()
+        {
+            return ReadTable<MIB_UDP6ROW_OWNER_PID>(false, AF_INET6);
+        }
+
+        // The tables start with a DWORD row count followed by the rows
+        private static List<T> ReadTable<T>(bool tcp, int ipVersion) where T : struct
+        {
+            uint Query(IntPtr table, ref int size) => tcp
+                ? GetExtendedTcpTable(table, ref size, false, ipVersion, TCP_TABLE_OWNER_PID_ALL, 0)
+                : GetExtendedUdpTable(table, ref size, false, ipVersion, UDP_TABLE_OWNER_PID, 0);
+
+            int size = 0;
+            Query(IntPtr.Zero, ref size);
+
+            IntPtr buffer = Marshal.AllocHGlobal(size);
+            try
+            {
+                uint result = Query(buffer, ref size);
+                if (result != 0) throw new ServiceOperationException($"Failed to query {(tcp ? "TCP" : "UDP")} table", (int)result);
+
+                int count = Marshal.ReadInt32(buffer);
+                int rowSize = Marshal.SizeOf<T>();
+                var rows = new List<T>(count);
+                for (int i = 0; i < count; i++)
+                {
+                    rows.Add(Marshal.PtrToStructure<T>(buffer + 4 + i * rowSize));
+                }
+                return rows;
+            }
+            finally
+            {
+                Marshal.FreeHGlobal(buffer);
+            }
+        }
+
+        // Table ports are in network byte order in the low 16 bits
+        public static ushort ToHostPort(uint tablePort) => (ushort)(((tablePort & 0xFF) << 8) | ((tablePort >> 8) & 0xFF));
+
+        // Sums the data bytes of the process's established IPv4 connections; IPv6 traffic is not counted.
+        // Per-connection statistics are enabled on first sight, so a connection only contributes traffic from that point on.
+        public static (ulong Received, ulong Sent) GetProcessTcpBytes(int pid)
+        {
+            ulong received = 0, sent = 0;
+            foreach (var conn in GetTcpConnections())
+            {
+                if (conn.dwOwningPid != (uint)pid || conn.dwState != MIB_TCP_STATE_ESTAB) continue;
+
+                var row = new MIB_TCPROW
+                {
+                    dwState = conn.dwState,
+                    dwLocalAddr = conn.dwLocalAddr,
+                    dwLocalPort = conn.dwLocalPort,
+                    dwRemoteAddr = conn.dwRemoteAddr,
+                    dwRemotePort = conn.dwRemotePort
+                };
+
+                var rw = new TCP_ESTATS_DATA_RW_v0 { EnableCollection = 1 };
+                SetPerTcpConnectionEStats(ref row, TcpConnectionEstatsData, ref rw, 0, (uint)Marshal.SizeOf<TCP_ESTATS_DATA_RW_v0>(), 0);
+
+                if (GetPerTcpConnectionEStats(ref row, TcpConnectionEstatsData, IntPtr.Zero, 0, 0, IntPtr.Zero, 0, 0,
+                        out var rod, 0, (uint)Marshal.SizeOf<TCP_ESTATS_DATA_ROD_v0>()) == 0)
+                {
+                    received += rod.DataBytesIn;
+                    sent += rod.DataBytesOut;
+                }
+            }
+            return (received, sent);
+        }
+    }
+}
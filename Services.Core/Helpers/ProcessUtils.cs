@@ -0,0 +1,171 @@
+using System;
+using System.Collections.Generic;
+using System.Runtime.InteropServices;
+using System.Text;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    public static class ProcessUtils
+    {
+        public const uint PROCESS_QUERY_INFORMATION = 0x0400;
+        public const uint PROCESS_QUERY_LIMITED_INFORMATION = 0x1000;
+        public const uint PROCESS_SET_INFORMATION = 0x0200;
+        public const uint PROCESS_VM_READ = 0x0010;
+        public const uint PROCESS_SUSPEND_RESUME = 0x0800;
+
+        [DllImport("kernel32.dll", SetLastError = true)]
+        public static extern IntPtr OpenProcess(uint dwDesiredAccess, bool bInheritHandle, int dwProcessId);
+
+        [DllImport("kernel32.dll", SetLastError = true)]
+        public static extern bool CloseHandle(IntPtr hObject);
+
+        [DllImport("kernel32.dll", SetLastError = true)]
+        public static extern bool GetProcessHandleCount(IntPtr hProcess, out uint pdwHandleCount);
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct PROCESS_MEMORY_COUNTERS
+        {
+            public uint cb;
+            public uint PageFaultCount;
+            public UIntPtr PeakWorkingSetSize;
+            public UIntPtr WorkingSetSize;
+            public UIntPtr QuotaPeakPagedPoolUsage;
+            public UIntPtr QuotaPagedPoolUsage;
+            public UIntPtr QuotaPeakNonPagedPoolUsage;
+            public UIntPtr QuotaNonPagedPoolUsage;
+            public UIntPtr PagefileUsage;
+            public UIntPtr PeakPagefileUsage;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct PROCESS_BASIC_INFORMATION
+        {
+            public IntPtr ExitStatus;
+            public IntPtr PebBaseAddress;
+            public IntPtr AffinityMask;
+            public IntPtr BasePriority;
+            public IntPtr UniqueProcessId;
+            public IntPtr InheritedFromUniqueProcessId;
+        }
+
+        [DllImport("ntdll.dll")]
+        private static extern int NtQueryInformationProcess(IntPtr hProcess, int infoClass, out PROCESS_BASIC_INFORMATION info, int size, out int returnLength);
+
+        [DllImport("ntdll.dll")]
+        private static extern int NtSuspendProcess(IntPtr hProcess);
+
+        [DllImport("ntdll.dll")]
+        private static extern int NtResumeProcess(IntPtr hProcess);
+
+        [DllImport("kernel32.dll", SetLastError = true)]
+        private static extern bool ReadProcessMemory(IntPtr hProcess, IntPtr baseAddress, byte[] buffer, IntPtr size, out IntPtr bytesRead);
+
+        [DllImport("kernel32.dll", SetLastError = true, EntryPoint = "K32GetProcessMemoryInfo")]
+        public static extern bool GetProcessMemoryInfo(IntPtr hProcess, out PROCESS_MEMORY_COUNTERS counters, uint cb);
+
+        // x64 layout offsets: PEB.ProcessParameters, RTL_USER_PROCESS_PARAMETERS.Environment / EnvironmentSize
+        private const int PebProcessParametersOffset = 0x20;
+        private const int ParametersEnvironmentOffset = 0x80;
+        private const int ParametersEnvironmentSizeOffset = 0x3F0;
+
+        // Reads the environment block from the PEB of a 64-bit process. Hidden "=C:" style entries are skipped.
+        public static Dictionary<string, string> ReadProcessEnvironment(int pid)
+        {
+            IntPtr hProcess = OpenProcess(PROCESS_QUERY_INFORMATION | PROCESS_VM_READ, false, pid);
+            if (hProcess == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError($"Failed to open process {pid}");
+
+            try
+            {
+                int status = NtQueryInformationProcess(hProcess, 0, out var pbi, Marshal.SizeOf<PROCESS_BASIC_INFORMATION>(), out _);
+                if (status != 0) throw new Exception($"NtQueryInformationProcess failed with status 0x{status:X8}");
+
+                IntPtr parameters = (IntPtr)BitConverter.ToInt64(ReadMemory(hProcess, pbi.PebBaseAddress + PebProcessParametersOffset, 8));
+                IntPtr environment = (IntPtr)BitConverter.ToInt64(ReadMemory(hProcess, parameters + ParametersEnvironmentOffset, 8));
+                long size = BitConverter.ToInt64(ReadMemory(hProcess, parameters + ParametersEnvironmentSizeOffset, 8));
+
+                var block = Encoding.Unicode.GetString(ReadMemory(hProcess, environment, (int)size));
+                var variables = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+                foreach (var entry in block.Split('\0'))
+                {
+                    if (entry.Length == 0) break;
+                    int separator = entry.IndexOf('=', 1);
+                    if (entry[0] == '=' || separator < 0) continue;
+                    variables[entry.Substring(0, separator)] = entry.Substring(separator + 1);
+                }
+                return variables;
+            }
+            finally
+            {
+                CloseHandle(hProcess);
+            }
+        }
+
+        private static byte[] ReadMemory(IntPtr hProcess, IntPtr address, int size)
+        {
+            var buffer = new byte[size];
+            if (!ReadProcessMemory(hProcess, address, buffer, (IntPtr)size, out _))
+                throw ServiceOperationException.FromLastError("Failed to read process memory");
+            return buffer;
+        }
+
+        // Suspends every thread of the process; calls nest, so each suspend needs a matching resume
+        public static void SuspendProcess(int pid) => SetProcessSuspended(pid, true);
+
+        public static void ResumeProcess(int pid) => SetProcessSuspended(pid, false);
+
+        private static void SetProcessSuspended(int pid, bool suspend)
+        {
+            IntPtr hProcess = OpenProcess(PROCESS_SUSPEND_RESUME, false, pid);
+            if (hProcess == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError($"Failed to open process {pid}");
+
+            try
+            {
+                int status = suspend ? NtSuspendProcess(hProcess) : NtResumeProcess(hProcess);
+                if (status != 0) throw new Exception($"{(suspend ? "NtSuspendProcess" : "NtResumeProcess")} failed with status 0x{status:X8}");
+            }
+            finally
+            {
+                CloseHandle(hProcess);
+            }
+        }
+
+        public static uint GetPageFaultCount(int pid)
+        {
+            IntPtr hProcess = OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, pid);
+            if (hProcess == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError($"Failed to open process {pid}");
+
+            try
+            {
+                if (!GetProcessMemoryInfo(hProcess, out var counters, (uint)Marshal.SizeOf<PROCESS_MEMORY_COUNTERS>()))
+                    throw ServiceOperationException.FromLastError("Failed to query process memory info");
+                return counters.PageFaultCount;
+            }
+            finally
+            {
+                CloseHandle(hProcess);
+            }
+        }
+
+        public static uint GetHandleCount(int pid)
+        {
+            IntPtr hProcess = OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, pid);
+            if (hProcess == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError($"Failed to open process {pid}");
+
+            try
+            {
+                if (!GetProcessHandleCount(hProcess, out var count))
+                    throw ServiceOperationException.FromLastError("Failed to query handle count");
+                return count;
+            }
+            finally
+            {
+                CloseHandle(hProcess);
+            }
+        }
+    }
+}
@@ -0,0 +1,60 @@
+using System;
+using System.Runtime.InteropServices;
+using Microsoft.Win32;
+using Microsoft.Win32.SafeHandles;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    // Kernel transaction (KTM) for registry writes: keys opened through it, and subkeys opened from
+    // those keys, only become visible on Commit. Disposing without committing rolls everything back.
+    public sealed class RegistryTransaction : IDisposable
+    {
+        private static readonly IntPtr HKEY_LOCAL_MACHINE = new(unchecked((int)0x80000002));
+        private const int KEY_READ = 0x20019;
+        private const int KEY_WRITE = 0x20006;
+
+        [DllImport("ktmw32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        private static extern IntPtr CreateTransaction(IntPtr lpTransactionAttributes, IntPtr uow, uint createOptions, uint isolationLevel, uint isolationFlags, uint timeout, string? description);
+
+        [DllImport("ktmw32.dll", SetLastError = true)]
+        private static extern bool CommitTransaction(IntPtr transaction);
+
+        [DllImport("advapi32.dll", CharSet = CharSet.Unicode)]
+        private static extern int RegOpenKeyTransacted(IntPtr hKey, string lpSubKey, uint ulOptions, int samDesired, out SafeRegistryHandle phkResult, IntPtr hTransaction, IntPtr pExtendedParameter);
+
+        private IntPtr _handle;
+
+        public RegistryTransaction(string description)
+        {
+            _handle = CreateTransaction(IntPtr.Zero, IntPtr.Zero, 0, 0, 0, 0, description);
+            if (_handle == new IntPtr(-1))
+            {
+                _handle = IntPtr.Zero;
+                throw ServiceOperationException.FromLastError("Failed to create registry transaction");
+            }
+        }
+
+        public RegistryKey OpenLocalMachineKey(string subKey, bool writable)
+        {
+            int error = RegOpenKeyTransacted(HKEY_LOCAL_MACHINE, subKey, 0, writable ? KEY_READ | KEY_WRITE : KEY_READ, out var hKey, _handle, IntPtr.Zero);
+            if (error != 0) throw new ServiceOperationException($"Failed to open registry key {subKey}", error);
+            return RegistryKey.FromHandle(hKey);
+        }
+
+        public void Commit()
+        {
+            if (!CommitTransaction(_handle))
+                throw ServiceOperationException.FromLastError("Failed to commit registry transaction");
+        }
+
+        public void Dispose()
+        {
+            if (_handle != IntPtr.Zero)
+            {
+                ProcessUtils.CloseHandle(_handle);
+                _handle = IntPtr.Zero;
+            }
+        }
+    }
+}
@@ -0,0 +1,143 @@
+using System;
+using System.Collections.Generic;
+using System.Diagnostics;
+using System.Runtime.InteropServices;
+using System.Threading;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    // Keeps one SCM connection open so batch operations don't reconnect per service
+    public sealed class SCMSession : IDisposable
+    {
+        private const int ERROR_SERVICE_ALREADY_RUNNING = 1056;
+        private const int ERROR_SERVICE_NOT_ACTIVE = 1062;
+        public static readonly TimeSpan DefaultStateTimeout = TimeSpan.FromSeconds(30);
+
+        private IntPtr _handle;
+
+        public SCMSession()
+        {
+            _handle = ServiceUtils.OpenSCManager(null, null, ServiceUtils.SC_MANAGER_CONNECT);
+            if (_handle == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError("Failed to open SC Manager");
+        }
+
+        public bool ServiceExists(string serviceName)
+        {
+            IntPtr hService = ServiceUtils.OpenService(_handle, serviceName, ServiceUtils.SERVICE_QUERY_STATUS);
+            if (hService == IntPtr.Zero) return false;
+
+            ServiceUtils.CloseServiceHandle(hService);
+            return true;
+        }
+
+        public (string Status, int Pid) QueryStatus(string serviceName)
+        {
+            return ServiceUtils.QueryServiceStatus(_handle, serviceName);
+        }
+
+        // Starts the services in the given order, waiting for each to run so dependents can follow
+        public Dictionary<string, Exception?> StartServices(IEnumerable<string> serviceNames, TimeSpan? timeout = null)
+        {
+            var results = new Dictionary<string, Exception?>(StringComparer.OrdinalIgnoreCase);
+            foreach (var name in serviceNames)
+            {
+                try
+                {
+                    StartService(name, timeout);
+                    results[name] = null;
+                }
+                catch (Exception ex)
+                {
+                    results[name] = ex;
+                }
+            }
+            return results;
+        }
+
+        public Dictionary<string, Exception?> StopServices(IEnumerable<string> serviceNames, TimeSpan? timeout = null)
+        {
+            var results = new Dictionary<string, Exception?>(StringComparer.OrdinalIgnoreCase);
+            foreach (var name in serviceNames)
+            {
+                try
+                {
+                    StopService(name, timeout);
+                    results[name] = null;
+                }
+                catch (Exception ex)
+                {
+                    results[name] = ex;
+                }
+            }
+            return results;
+        }
+
+        // Safe to call from several threads at once; each call opens its own service handle
+        public void StartService(string serviceName, TimeSpan? timeout = null)
+        {
+            WithService(serviceName, ServiceUtils.SERVICE_START, hService =>
+            {
+                if (!ServiceUtils.StartService(hService, 0, null) && Marshal.GetLastWin32Error() != ERROR_SERVICE_ALREADY_RUNNING)
+                    throw ServiceOperationException.FromLastError($"Failed to start service {serviceName}");
+            });
+            WaitForStatus(serviceName, "运行中", timeout ?? DefaultStateTimeout);
+        }
+
+        public void StopService(string serviceName, TimeSpan? timeout = null)
+        {
+            WithService(serviceName, ServiceUtils.SERVICE_STOP, hService =>
+            {
+                var status = new ServiceUtils.SERVICE_STATUS();
+                if (!ServiceUtils.ControlService(hService, ServiceUtils.SERVICE_CONTROL_STOP, ref status) && Marshal.GetLastWin32Error() != ERROR_SERVICE_NOT_ACTIVE)
+                    throw ServiceOperationException.FromLastError($"Failed to stop service {serviceName}");
+            });
+            WaitForStatus(serviceName, "已停止", timeout ?? DefaultStateTimeout);
+        }
+
+        private void WithService(string serviceName, uint access, Action<IntPtr> action)
+        {
+            IntPtr hService = ServiceUtils.OpenService(_handle, serviceName, access);
+            if (hService == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError($"Failed to open service {serviceName}");
+
+            try
+            {
+                action(hService);
+            }
+            finally
+            {
+                ServiceUtils.CloseServiceHandle(hService);
+            }
+        }
+
+        private void WaitForStatus(string serviceName, string status, TimeSpan timeout)
+        {
+            var stopwatch = Stopwatch.StartNew();
+            while (QueryStatus(serviceName).Status != status)
+            {
+                if (stopwatch.Elapsed > timeout)
+                    throw new TimeoutException($"Service {serviceName} did not reach state {status} within {timeout.TotalSeconds}s");
+                Thread.Sleep(250);
+            }
+        }
+
+        public void Close() => Dispose();
+
+        public void Dispose()
+        {
+            if (_handle != IntPtr.Zero)
+            {
+                ServiceUtils.CloseServiceHandle(_handle);
+                _handle = IntPtr.Zero;
+            }
+            GC.SuppressFinalize(this);
+        }
+
+        ~SCMSession()
+        {
+            Dispose();
+        }
+    }
+}
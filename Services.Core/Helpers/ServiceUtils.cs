@@ -1,5 +1,11 @@
 using System;
+using System.Collections.Generic;
+using System.Linq;
 using System.Runtime.InteropServices;
+using System.Globalization;
+using System.Text.Json;
+using Microsoft.Win32;
+using Services.Core.Models;
 
 namespace Services.Core.Helpers
 {
@@ -13,6 +19,55 @@ namespace Services.Core.Helpers
         public const uint SERVICE_AUTO_START = 0x00000002;
         public const uint SERVICE_ERROR_NORMAL = 0x00000001;
         public const uint DELETE = 0x00010000;
+        public const uint SERVICE_START = 0x0010;
+        public const uint SERVICE_STOP = 0x0020;
+        public const uint SERVICE_CONTROL_STOP = 0x00000001;
+        public const uint SERVICE_CHANGE_CONFIG = 0x0002;
+        public const uint SERVICE_NO_CHANGE = 0xFFFFFFFF;
+        public const uint SERVICE_QUERY_CONFIG = 0x0001;
+        public const uint SERVICE_CONFIG_PRESHUTDOWN_INFO = 7;
+        public const uint SC_MANAGER_ENUMERATE_SERVICE = 0x0004;
+        private const int SC_ENUM_PROCESS_INFO = 0;
+        private const uint SERVICE_WIN32 = 0x00000030;
+        private const uint SERVICE_ACTIVE = 0x00000001;
+        private const int ERROR_MORE_DATA = 234;
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct SERVICE_PRESHUTDOWN_INFO
+        {
+            public uint dwPreshutdownTimeout;
+        }
+
+        public const uint SERVICE_CONFIG_FAILURE_ACTIONS = 2;
+
+        [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+        public struct SERVICE_FAILURE_ACTIONS
+        {
+            public uint dwResetPeriod;
+            public string? lpRebootMsg;
+            public string? lpCommand;
+            public uint cActions;
+            public IntPtr lpsaActions;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct SC_ACTION
+        {
+            public uint Type;
+            public uint Delay;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct SERVICE_STATUS
+        {
+            public uint dwServiceType;
+            public uint dwCurrentState;
+            public uint dwControlsAccepted;
+            public uint dwWin32ExitCode;
+            public uint dwServiceSpecificExitCode;
+            public uint dwCheckPoint;
+            public uint dwWaitHint;
+        }
 
         [StructLayout(LayoutKind.Sequential)]
         public struct SERVICE_STATUS_PROCESS
@@ -28,6 +83,18 @@ namespace Services.Core.Helpers
             public uint dwServiceFlags;
         }
 
+        [StructLayout(LayoutKind.Sequential)]
+        private struct ENUM_SERVICE_STATUS_PROCESS
+        {
+            public IntPtr lpServiceName;
+            public IntPtr lpDisplayName;
+            public SERVICE_STATUS_PROCESS ServiceStatusProcess;
+        }
+
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        private static extern bool EnumServicesStatusEx(IntPtr hSCManager, int infoLevel, uint dwServiceType, uint dwServiceState,
+            IntPtr lpServices, uint cbBufSize, out uint pcbBytesNeeded, out uint lpServicesReturned, ref uint lpResumeHandle, string? pszGroupName);
+
         [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
         public static extern IntPtr OpenSCManager(string? machineName, string? databaseName, uint dwAccess);
 
@@ -50,6 +117,33 @@ namespace Services.Core.Helpers
             string? lpServiceStartName,
             string? lpPassword);
 
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        [return: MarshalAs(UnmanagedType.Bool)]
+        public static extern bool ChangeServiceConfig(
+            IntPtr hService,
+            uint dwServiceType,
+            uint dwStartType,
+            uint dwErrorControl,
+            string? lpBinaryPathName,
+            string? lpLoadOrderGroup,
+            IntPtr lpdwTagId,
+            string? lpDependencies,
+            string? lpServiceStartName,
+            string? lpPassword,
+            string? lpDisplayName);
+
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        [return: MarshalAs(UnmanagedType.Bool)]
+        public static extern bool QueryServiceConfig2(IntPtr hService, uint dwInfoLevel, IntPtr lpBuffer, int cbBufSize, out int pcbBytesNeeded);
+
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        [return: MarshalAs(UnmanagedType.Bool)]
+        public static extern bool ChangeServiceConfig2(IntPtr hService, uint dwInfoLevel, ref SERVICE_PRESHUTDOWN_INFO lpInfo);
+
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        [return: MarshalAs(UnmanagedType.Bool)]
+        public static extern bool ChangeServiceConfig2(IntPtr hService, uint dwInfoLevel, ref SERVICE_FAILURE_ACTIONS lpInfo);
+
         [DllImport("advapi32.dll", SetLastError = true)]
         [return: MarshalAs(UnmanagedType.Bool)]
         public static extern bool DeleteService(IntPtr hService);
@@ -57,20 +151,289 @@ namespace Services.Core.Helpers
         [DllImport("advapi32.dll", SetLastError = true)]
         public static extern bool CloseServiceHandle(IntPtr hSCObject);
 
+        [DllImport("advapi32.dll", CharSet = CharSet.Unicode)]
+        private static extern int RegQueryInfoKey(Microsoft.Win32.SafeHandles.SafeRegistryHandle hKey, IntPtr lpClass, IntPtr lpcchClass, IntPtr lpReserved,
+            IntPtr lpcSubKeys, IntPtr lpcbMaxSubKeyLen, IntPtr lpcbMaxClassLen, IntPtr lpcValues, IntPtr lpcbMaxValueNameLen,
+            IntPtr lpcbMaxValueLen, IntPtr lpcbSecurityDescriptor, out long lpftLastWriteTime);
+
+        public static DateTime GetKeyLastWriteTime(RegistryKey key)
+        {
+            int error = RegQueryInfoKey(key.Handle, IntPtr.Zero, IntPtr.Zero, IntPtr.Zero, IntPtr.Zero, IntPtr.Zero, IntPtr.Zero,
+                IntPtr.Zero, IntPtr.Zero, IntPtr.Zero, IntPtr.Zero, out long lastWrite);
+            if (error != 0) throw new ServiceOperationException("Failed to query registry key information", error);
+            return DateTime.FromFileTime(lastWrite);
+        }
+
         [DllImport("advapi32.dll", SetLastError = true)]
         public static extern bool QueryServiceStatusEx(IntPtr hService, int infoLevel, IntPtr lpBuffer, uint cbBufSize, out uint pcbBytesNeeded);
 
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        [return: MarshalAs(UnmanagedType.Bool)]
+        public static extern bool StartService(IntPtr hService, uint dwNumServiceArgs, string[]? lpServiceArgVectors);
+
+        [DllImport("advapi32.dll", SetLastError = true)]
+        [return: MarshalAs(UnmanagedType.Bool)]
+        public static extern bool ControlService(IntPtr hService, uint dwControl, ref SERVICE_STATUS lpServiceStatus);
+
+        public static string FormatStartType(int start, bool delayed)
+        {
+            return start switch
+            {
+                0 => "Boot",
+                1 => "System",
+                2 => delayed ? "AutomaticDelayed" : "Automatic",
+                3 => "Manual",
+                4 => "Disabled",
+                _ => "Unknown"
+            };
+        }
+
+        public static string FormatSidType(int sidType)
+        {
+            return sidType switch
+            {
+                0 => "None",
+                1 => "Unrestricted",
+                3 => "Restricted",
+                _ => "Unknown"
+            };
+        }
+
+        // Parses the serialized SERVICE_FAILURE_ACTIONS blob stored in the service key's FailureActions value:
+        // 32-bit dwResetPeriod, lpRebootMsg, lpCommand, cActions and lpsaActions (the pointers are unused
+        // placeholders), then cActions x (SC_ACTION_TYPE, dwDelay)
+        public static ServiceFailureActions? ParseFailureActions(byte[]? data, string? command)
+        {
+            if (data == null || data.Length < 20) return null;
+
+            var result = new ServiceFailureActions
+            {
+                ResetPeriodSeconds = BitConverter.ToInt32(data, 0),
+                Command = command
+            };
+
+            int count = BitConverter.ToInt32(data, 12);
+            for (int i = 0; i < count && 20 + i * 8 + 8 <= data.Length; i++)
+            {
+                int offset = 20 + i * 8;
+                result.Actions.Add(new ServiceFailureAction
+                {
+                    Type = BitConverter.ToInt32(data, offset) switch
+                    {
+                        0 => "none",
+                        1 => "restart",
+                        2 => "reboot",
+                        3 => "run-program",
+                        _ => "unknown"
+                    },
+                    Delay = TimeSpan.FromMilliseconds(BitConverter.ToUInt32(data, offset + 4))
+                });
+            }
+
+            return result;
+        }
+
+        // Running Win32 services keyed by host PID; shared svchost processes map to several names
+        public static Dictionary<int, List<string>> GetServiceNamesByPid()
+        {
+            IntPtr hSCM = OpenSCManager(null, null, SC_MANAGER_ENUMERATE_SERVICE);
+            if (hSCM == IntPtr.Zero) throw ServiceOperationException.FromLastError("Failed to open SC Manager");
+
+            var result = new Dictionary<int, List<string>>();
+            IntPtr buffer = IntPtr.Zero;
+            try
+            {
+                uint resume = 0;
+                EnumServicesStatusEx(hSCM, SC_ENUM_PROCESS_INFO, SERVICE_WIN32, SERVICE_ACTIVE, IntPtr.Zero, 0, out uint needed, out _, ref resume, null);
+                if (Marshal.GetLastWin32Error() != ERROR_MORE_DATA)
+                    throw ServiceOperationException.FromLastError("Failed to enumerate services");
+
+                buffer = Marshal.AllocHGlobal((int)needed);
+                resume = 0;
+                if (!EnumServicesStatusEx(hSCM, SC_ENUM_PROCESS_INFO, SERVICE_WIN32, SERVICE_ACTIVE, buffer, needed, out _, out uint count, ref resume, null))
+                    throw ServiceOperationException.FromLastError("Failed to enumerate services");
+
+                int entrySize = Marshal.SizeOf<ENUM_SERVICE_STATUS_PROCESS>();
+                for (int i = 0; i < count; i++)
+                {
+                    var entry = Marshal.PtrToStructure<ENUM_SERVICE_STATUS_PROCESS>(buffer + i * entrySize);
+                    int pid = (int)entry.ServiceStatusProcess.dwProcessId;
+                    if (pid == 0) continue;
+
+                    if (!result.TryGetValue(pid, out var names)) result[pid] = names = new List<string>();
+                    names.Add(Marshal.PtrToStringUni(entry.lpServiceName) ?? "");
+                }
+                return result;
+            }
+            finally
+            {
+                if (buffer != IntPtr.Zero) Marshal.FreeHGlobal(buffer);
+                CloseServiceHandle(hSCM);
+            }
+        }
+
+        // Environment blocks are REG_MULTI_SZ lists of NAME=value; a leading '=' belongs to the name (e.g. "=C:")
+        public static Dictionary<string, string> ParseEnvironmentLines(string[]? lines)
+        {
+            var result = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+            foreach (var line in lines ?? Array.Empty<string>())
+            {
+                int eq = line.IndexOf('=', 1);
+                if (eq > 0) result[line[..eq]] = line[(eq + 1)..];
+            }
+            return result;
+        }
+
+        public static string[] FormatEnvironmentLines(IDictionary<string, string> variables)
+        {
+            return variables.Select(kv => $"{kv.Key}={kv.Value}").ToArray();
+        }
+
+        // Stored in Parameters\RestartPolicy; services configured before it existed fall back to the
+        // MaxRestarts / RestartDelaySeconds / RestartCooldownSeconds values of Parameters
+        public static RestartPolicy ReadRestartPolicy(RegistryKey paramsKey)
+        {
+            var policy = new RestartPolicy();
+            using var policyKey = paramsKey.OpenSubKey("RestartPolicy");
+            if (policyKey == null)
+            {
+                if (paramsKey.GetValue("MaxRestarts") is int maxRestarts) policy.MaxAttempts = maxRestarts;
+                if (paramsKey.GetValue("RestartDelaySeconds") is int delay && delay > 0)
+                {
+                    policy.InitialDelay = TimeSpan.FromSeconds(delay);
+                    policy.MaxDelay = TimeSpan.FromSeconds(delay * 16);
+                }
+                if (paramsKey.GetValue("RestartCooldownSeconds") is int cooldown) policy.ResetAfter = TimeSpan.FromSeconds(cooldown);
+                return policy;
+            }
+
+            if (policyKey.GetValue("MaxAttempts") is int attempts) policy.MaxAttempts = attempts;
+            if (policyKey.GetValue("InitialDelayMs") is int initial) policy.InitialDelay = TimeSpan.FromMilliseconds(initial);
+            if (policyKey.GetValue("BackoffFactor") is string factor && double.TryParse(factor, NumberStyles.Float, CultureInfo.InvariantCulture, out var f)) policy.BackoffFactor = f;
+            if (policyKey.GetValue("MaxDelayMs") is int maxDelay) policy.MaxDelay = TimeSpan.FromMilliseconds(maxDelay);
+            if (policyKey.GetValue("ResetAfterSeconds") is int reset) policy.ResetAfter = TimeSpan.FromSeconds(reset);
+            return policy;
+        }
+
+        public static void WriteRestartPolicy(RegistryKey paramsKey, RestartPolicy policy)
+        {
+            using (var policyKey = paramsKey.CreateSubKey("RestartPolicy", true))
+            {
+                policyKey.SetValue("MaxAttempts", policy.MaxAttempts, RegistryValueKind.DWord);
+                policyKey.SetValue("InitialDelayMs", (int)policy.InitialDelay.TotalMilliseconds, RegistryValueKind.DWord);
+                policyKey.SetValue("BackoffFactor", policy.BackoffFactor.ToString(CultureInfo.InvariantCulture), RegistryValueKind.String);
+                policyKey.SetValue("MaxDelayMs", (int)policy.MaxDelay.TotalMilliseconds, RegistryValueKind.DWord);
+                policyKey.SetValue("ResetAfterSeconds", (int)policy.ResetAfter.TotalSeconds, RegistryValueKind.DWord);
+            }
+
+            paramsKey.DeleteValue("MaxRestarts", false);
+            paramsKey.DeleteValue("RestartDelaySeconds", false);
+            paramsKey.DeleteValue("RestartCooldownSeconds", false);
+        }
+
+        // Stored in Parameters\HealthCheck; null when no health check is configured
+        public static HealthCheckConfig? ReadHealthCheck(RegistryKey paramsKey)
+        {
+            using var checkKey = paramsKey.OpenSubKey("HealthCheck");
+            if (checkKey?.GetValue("Type") is not string type || checkKey.GetValue("Target") is not string target) return null;
+
+            var config = new HealthCheckConfig { Type = type, Target = target };
+            if (checkKey.GetValue("IntervalSeconds") is int interval) config.IntervalSeconds = interval;
+            if (checkKey.GetValue("TimeoutSeconds") is int timeout) config.TimeoutSeconds = timeout;
+            if (checkKey.GetValue("UnhealthyThreshold") is int threshold) config.UnhealthyThreshold = threshold;
+            return config;
+        }
+
+        public static void WriteHealthCheck(RegistryKey paramsKey, HealthCheckConfig? config)
+        {
+            if (config == null)
+            {
+                paramsKey.DeleteSubKey("HealthCheck", false);
+                return;
+            }
+
+            using var checkKey = paramsKey.CreateSubKey("HealthCheck", true);
+            checkKey.SetValue("Type", config.Type, RegistryValueKind.String);
+            checkKey.SetValue("Target", config.Target, RegistryValueKind.String);
+            checkKey.SetValue("IntervalSeconds", config.IntervalSeconds, RegistryValueKind.DWord);
+            checkKey.SetValue("TimeoutSeconds", config.TimeoutSeconds, RegistryValueKind.DWord);
+            checkKey.SetValue("UnhealthyThreshold", config.UnhealthyThreshold, RegistryValueKind.DWord);
+        }
+
+        public const string DailyStartDateFormat = "yyyy-MM-dd";
+
+        // A count recorded on an earlier day reads as 0
+        public static (int Count, string? Date) ReadDailyStartCount(RegistryKey paramsKey)
+        {
+            var date = paramsKey.GetValue("DailyStartCountDate") as string;
+            int count = paramsKey.GetValue("DailyStartCount") is int c ? c : 0;
+            return date == DateTime.Now.ToString(DailyStartDateFormat, CultureInfo.InvariantCulture) ? (count, date) : (0, date);
+        }
+
+        public const int MaxPidHistory = 20;
+
+        // PidHistory is a REG_MULTI_SZ of "pid|start|stop" entries, oldest first, stop left empty while running
+        public static List<PidHistoryEntry> ParsePidHistory(string[]? values)
+        {
+            var history = new List<PidHistoryEntry>();
+            if (values == null) return history;
+
+            foreach (var value in values)
+            {
+                var parts = value.Split('|');
+                if (parts.Length < 2 || !int.TryParse(parts[0], out var pid) || !DateTime.TryParse(parts[1], out var start)) continue;
+
+                var entry = new PidHistoryEntry { Pid = pid, StartTime = start };
+                if (parts.Length > 2 && DateTime.TryParse(parts[2], out var stop)) entry.StopTime = stop;
+                history.Add(entry);
+            }
+
+            return history;
+        }
+
+        public static string[] FormatPidHistory(IEnumerable<PidHistoryEntry> history)
+        {
+            return history.Select(e => $"{e.Pid}|{e.StartTime:o}|{e.StopTime?.ToString("o")}").ToArray();
+        }
+
+        public const int MaxExitCodeHistory = 10;
+
+        // ExitCodeHistory is stored as a JSON array, oldest first
+        public static List<ExitCodeEntry> ParseExitCodeHistory(string? json)
+        {
+            if (string.IsNullOrEmpty(json)) return new List<ExitCodeEntry>();
+            try
+            {
+                return JsonSerializer.Deserialize<List<ExitCodeEntry>>(json) ?? new List<ExitCodeEntry>();
+            }
+            catch (JsonException)
+            {
+                return new List<ExitCodeEntry>();
+            }
+        }
+
         public static (string Status, int Pid) GetServiceStatus(string serviceName)
         {
-            IntPtr hSCManager = IntPtr.Zero;
+            IntPtr hSCManager = OpenSCManager(null, null, SC_MANAGER_CONNECT);
+            if (hSCManager == IntPtr.Zero) return ("未知", 0);
+
+            try
+            {
+                return QueryServiceStatus(hSCManager, serviceName);
+            }
+            finally
+            {
+                CloseServiceHandle(hSCManager);
+            }
+        }
+
+        public static (string Status, int Pid) QueryServiceStatus(IntPtr hSCManager, string serviceName)
+        {
             IntPtr hService = IntPtr.Zero;
             IntPtr ptr = IntPtr.Zero;
 
             try
             {
-                hSCManager = OpenSCManager(null, null, SC_MANAGER_CONNECT);
-                if (hSCManager == IntPtr.Zero) return ("未知", 0);
-
                 hService = OpenService(hSCManager, serviceName, SERVICE_QUERY_STATUS);
                 if (hService == IntPtr.Zero) return ("未知", 0);
 
@@ -86,6 +449,9 @@ namespace Services.Core.Helpers
                         2 => "启动中",
                         3 => "停止中",
                         4 => "运行中",
+                        5 => "继续中",
+                        6 => "暂停中",
+                        7 => "已暂停",
                         _ => "未知"
                     };
                     return (statusStr, (int)status.dwProcessId);
@@ -99,7 +465,6 @@ namespace Services.Core.Helpers
             {
                 if (ptr != IntPtr.Zero) Marshal.FreeHGlobal(ptr);
                 if (hService != IntPtr.Zero) CloseServiceHandle(hService);
-                if (hSCManager != IntPtr.Zero) CloseServiceHandle(hSCManager);
             }
 
             return ("未知", 0);
@@ -0,0 +1,108 @@
+using System;
+using System.Globalization;
+using System.Linq;
+using System.Xml.Linq;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    public static class ServiceXmlHelper
+    {
+        // <ServiceConfig>
+        //   <Executable/> <Arguments/> <WorkingDirectory/> <DisplayName/> <Alias/> <Description/> <StartType>Auto|Manual|Disabled</StartType>
+        //   <RecoveryActions ResetPeriod="86400"><Action Type="restart" Delay="60000"/></RecoveryActions>
+        // </ServiceConfig>
+        public static WindowsServiceXml Parse(XDocument document)
+        {
+            var root = document.Root;
+            if (root == null || root.Name.LocalName != "ServiceConfig")
+                throw new FormatException("XML root element must be <ServiceConfig>");
+
+            string? Child(string name) => root.Element(name)?.Value.Trim();
+
+            var definition = new WindowsServiceXml
+            {
+                Executable = Child("Executable") ?? "",
+                Arguments = Child("Arguments"),
+                WorkingDirectory = Child("WorkingDirectory"),
+                DisplayName = Child("DisplayName") ?? "",
+                Alias = Child("Alias"),
+                Description = Child("Description"),
+                StartType = Child("StartType") ?? nameof(ServiceStartupType.Auto)
+            };
+            if (string.IsNullOrEmpty(definition.Executable))
+                throw new FormatException("<Executable> is required");
+
+            var recovery = root.Element("RecoveryActions");
+            if (recovery != null)
+            {
+                definition.RecoveryActions = new ServiceFailureActions
+                {
+                    ResetPeriodSeconds = ParseInt(recovery.Attribute("ResetPeriod")?.Value, 86400),
+                    Command = recovery.Attribute("Command")?.Value
+                };
+                foreach (var action in recovery.Elements("Action"))
+                {
+                    definition.RecoveryActions.Actions.Add(new ServiceFailureAction
+                    {
+                        Type = action.Attribute("Type")?.Value ?? "none",
+                        Delay = TimeSpan.FromMilliseconds(ParseInt(action.Attribute("Delay")?.Value, 0))
+                    });
+                }
+            }
+
+            return definition;
+        }
+
+        public static XDocument Build(WindowsServiceXml definition)
+        {
+            var root = new XElement("ServiceConfig",
+                new XElement("Executable", definition.Executable),
+                new XElement("DisplayName", definition.DisplayName),
+                new XElement("StartType", definition.StartType));
+            if (!string.IsNullOrEmpty(definition.Arguments)) root.Add(new XElement("Arguments", definition.Arguments));
+            if (!string.IsNullOrEmpty(definition.WorkingDirectory)) root.Add(new XElement("WorkingDirectory", definition.WorkingDirectory));
+            if (!string.IsNullOrEmpty(definition.Alias)) root.Add(new XElement("Alias", definition.Alias));
+            if (!string.IsNullOrEmpty(definition.Description)) root.Add(new XElement("Description", definition.Description));
+
+            if (definition.RecoveryActions != null)
+            {
+                var recovery = new XElement("RecoveryActions",
+                    new XAttribute("ResetPeriod", definition.RecoveryActions.ResetPeriodSeconds));
+                if (!string.IsNullOrEmpty(definition.RecoveryActions.Command))
+                    recovery.Add(new XAttribute("Command", definition.RecoveryActions.Command));
+                recovery.Add(definition.RecoveryActions.Actions.Select(a => new XElement("Action",
+                    new XAttribute("Type", a.Type),
+                    new XAttribute("Delay", (long)a.Delay.TotalMilliseconds))));
+                root.Add(recovery);
+            }
+
+            return new XDocument(root);
+        }
+
+        // sc.exe failure syntax: restart/60000/run/1000/""/0
+        public static string FormatScFailureActions(ServiceFailureActions actions)
+        {
+            return string.Join("/", actions.Actions.Select(a =>
+            {
+                var type = a.Type switch
+                {
+                    "restart" => "restart",
+                    "reboot" => "reboot",
+                    "run-program" => "run",
+                    "none" => "\"\"",
+                    _ => throw new FormatException($"Unknown recovery action type: {a.Type}")
+                };
+                return $"{type}/{(long)a.Delay.TotalMilliseconds}";
+            }));
+        }
+
+        private static int ParseInt(string? value, int defaultValue)
+        {
+            if (value == null) return defaultValue;
+            if (!int.TryParse(value, NumberStyles.Integer, CultureInfo.InvariantCulture, out var result))
+                throw new FormatException($"Invalid number: {value}");
+            return result;
+        }
+    }
+}
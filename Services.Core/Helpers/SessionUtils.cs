@@ -0,0 +1,76 @@
+using System;
+using System.Runtime.InteropServices;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    public static class SessionUtils
+    {
+        private static readonly IntPtr WTS_CURRENT_SERVER_HANDLE = IntPtr.Zero;
+
+        private enum WTS_INFO_CLASS
+        {
+            WTSUserName = 5,
+            WTSDomainName = 7,
+            WTSConnectState = 8
+        }
+
+        // Indexed by WTS_CONNECTSTATE_CLASS
+        private static readonly string[] ConnectStateNames =
+        {
+            "Active", "Connected", "ConnectQuery", "Shadow", "Disconnected", "Idle", "Listen", "Reset", "Down", "Init"
+        };
+
+        [DllImport("kernel32.dll", SetLastError = true)]
+        private static extern bool ProcessIdToSessionId(uint dwProcessId, out uint pSessionId);
+
+        [DllImport("wtsapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        private static extern bool WTSQuerySessionInformation(IntPtr hServer, uint sessionId, WTS_INFO_CLASS wtsInfoClass, out IntPtr ppBuffer, out uint pBytesReturned);
+
+        [DllImport("wtsapi32.dll")]
+        private static extern void WTSFreeMemory(IntPtr pMemory);
+
+        public static SessionInfo GetProcessSession(int pid)
+        {
+            if (!ProcessIdToSessionId((uint)pid, out uint sessionId))
+                throw ServiceOperationException.FromLastError($"Failed to query session of process {pid}");
+
+            int state = QueryInt32(sessionId, WTS_INFO_CLASS.WTSConnectState);
+            return new SessionInfo
+            {
+                SessionId = sessionId,
+                Username = QueryString(sessionId, WTS_INFO_CLASS.WTSUserName),
+                Domain = QueryString(sessionId, WTS_INFO_CLASS.WTSDomainName),
+                SessionState = state >= 0 && state < ConnectStateNames.Length ? ConnectStateNames[state] : "Unknown"
+            };
+        }
+
+        private static string QueryString(uint sessionId, WTS_INFO_CLASS infoClass)
+        {
+            if (!WTSQuerySessionInformation(WTS_CURRENT_SERVER_HANDLE, sessionId, infoClass, out var buffer, out _))
+                throw ServiceOperationException.FromLastError($"Failed to query session {sessionId}");
+            try
+            {
+                return Marshal.PtrToStringUni(buffer) ?? "";
+            }
+            finally
+            {
+                WTSFreeMemory(buffer);
+            }
+        }
+
+        private static int QueryInt32(uint sessionId, WTS_INFO_CLASS infoClass)
+        {
+            if (!WTSQuerySessionInformation(WTS_CURRENT_SERVER_HANDLE, sessionId, infoClass, out var buffer, out _))
+                throw ServiceOperationException.FromLastError($"Failed to query session {sessionId}");
+            try
+            {
+                return Marshal.ReadInt32(buffer);
+            }
+            finally
+            {
+                WTSFreeMemory(buffer);
+            }
+        }
+    }
+}
@@ -0,0 +1,81 @@
+using System;
+using System.IO;
+using System.Linq;
+using System.Text;
+using System.Xml.Linq;
+
+namespace Services.Core.Helpers
+{
+    public static class TaskSchedulerHelper
+    {
+        public const string TaskFolder = @"\WindowsServiceManager\";
+        private static readonly XNamespace TaskNs = "http://schemas.microsoft.com/windows/2004/02/mit/task";
+
+        // Maps an SCM account name (ObjectName) to the principal used by Task Scheduler
+        public static string ToTaskUserId(string? account)
+        {
+            if (string.IsNullOrEmpty(account) || string.Equals(account, "LocalSystem", StringComparison.OrdinalIgnoreCase))
+                return "S-1-5-18";
+            if (string.Equals(account, @"NT AUTHORITY\LocalService", StringComparison.OrdinalIgnoreCase))
+                return "S-1-5-19";
+            if (string.Equals(account, @"NT AUTHORITY\NetworkService", StringComparison.OrdinalIgnoreCase))
+                return "S-1-5-20";
+            return account;
+        }
+
+        public static XDocument BuildBootTaskXml(string description, string userId, string exePath, string? args, string? workingDir)
+        {
+            bool isBuiltinAccount = userId.StartsWith("S-1-5-", StringComparison.Ordinal);
+
+            var exec = new XElement(TaskNs + "Exec", new XElement(TaskNs + "Command", exePath));
+            if (!string.IsNullOrEmpty(args)) exec.Add(new XElement(TaskNs + "Arguments", args));
+            if (!string.IsNullOrEmpty(workingDir)) exec.Add(new XElement(TaskNs + "WorkingDirectory", workingDir));
+
+            return new XDocument(
+                new XElement(TaskNs + "Task",
+                    new XAttribute("version", "1.2"),
+                    new XElement(TaskNs + "RegistrationInfo",
+                        new XElement(TaskNs + "Description", description)),
+                    new XElement(TaskNs + "Triggers",
+                        new XElement(TaskNs + "BootTrigger",
+                            new XElement(TaskNs + "Enabled", "true"))),
+                    new XElement(TaskNs + "Principals",
+                        new XElement(TaskNs + "Principal",
+                            new XAttribute("id", "Author"),
+                            new XElement(TaskNs + "UserId", userId),
+                            // Named accounts have no stored password, so the task runs as S4U
+                            new XElement(TaskNs + "LogonType", isBuiltinAccount ? "ServiceAccount" : "S4U"),
+                            new XElement(TaskNs + "RunLevel", "HighestAvailable"))),
+                    new XElement(TaskNs + "Settings",
+                        new XElement(TaskNs + "MultipleInstancesPolicy", "IgnoreNew"),
+                        new XElement(TaskNs + "DisallowStartIfOnBatteries", "false"),
+                        new XElement(TaskNs + "StopIfGoingOnBatteries", "false"),
+                        new XElement(TaskNs + "ExecutionTimeLimit", "PT0S"),
+                        new XElement(TaskNs + "Enabled", "true")),
+                    new XElement(TaskNs + "Actions",
+                        new XAttribute("Context", "Author"),
+                        exec)));
+        }
+
+        // Reads the first Exec action of a task definition as exported by `schtasks /Query /XML`
+        public static (string ExePath, string? Args, string? WorkingDir) ParseExecAction(XDocument document)
+        {
+            var exec = document.Descendants(TaskNs + "Exec").FirstOrDefault();
+            var command = exec?.Element(TaskNs + "Command")?.Value;
+            if (string.IsNullOrWhiteSpace(command)) throw new Exception("Task has no executable action");
+
+            return (Environment.ExpandEnvironmentVariables(command.Trim('"')),
+                exec!.Element(TaskNs + "Arguments")?.Value,
+                exec.Element(TaskNs + "WorkingDirectory")?.Value);
+        }
+
+        // schtasks /XML expects a UTF-16 encoded file
+        public static string WriteTaskXml(XDocument document)
+        {
+            var path = Path.Combine(Path.GetTempPath(), $"wsm_task_{Guid.NewGuid():N}.xml");
+            using var writer = new StreamWriter(path, false, Encoding.Unicode);
+            document.Save(writer);
+            return path;
+        }
+    }
+}
@@ -0,0 +1,219 @@
+using System;
+using System.Collections.Generic;
+using System.Runtime.InteropServices;
+using Services.Core.Models;
+using System.Text;
+
+namespace Services.Core.Helpers
+{
+    public static class TokenUtils
+    {
+        public const uint TOKEN_QUERY = 0x0008;
+        public const uint TOKEN_ADJUST_PRIVILEGES = 0x0020;
+        public const string SE_SHUTDOWN_NAME = "SeShutdownPrivilege";
+        private const int ERROR_NOT_ALL_ASSIGNED = 1300;
+        public const int TokenPrivilegesClass = 3;
+        public const int TokenElevationTypeClass = 18;
+        public const int TokenIntegrityLevelClass = 25;
+        public const int TokenMandatoryPolicyClass = 27;
+
+        public const uint TOKEN_MANDATORY_POLICY_NO_WRITE_UP = 0x1;
+        public const uint TOKEN_MANDATORY_POLICY_NEW_PROCESS_MIN = 0x2;
+
+        public const uint SE_PRIVILEGE_ENABLED_BY_DEFAULT = 0x00000001;
+        public const uint SE_PRIVILEGE_ENABLED = 0x00000002;
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct LUID
+        {
+            public uint LowPart;
+            public int HighPart;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        public struct LUID_AND_ATTRIBUTES
+        {
+            public LUID Luid;
+            public uint Attributes;
+        }
+
+        [DllImport("advapi32.dll", SetLastError = true)]
+        public static extern bool OpenProcessToken(IntPtr processHandle, uint desiredAccess, out IntPtr tokenHandle);
+
+        [DllImport("advapi32.dll", SetLastError = true)]
+        public static extern bool GetTokenInformation(IntPtr tokenHandle, int tokenInformationClass, IntPtr tokenInformation, int tokenInformationLength, out int returnLength);
+
+        public const int LOGON32_LOGON_SERVICE = 5;
+        public const int LOGON32_PROVIDER_DEFAULT = 0;
+
+        [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+        public struct PROFILEINFO
+        {
+            public int dwSize;
+            public int dwFlags;
+            public string lpUserName;
+            public string? lpProfilePath;
+            public string? lpDefaultPath;
+            public string? lpServerName;
+            public string? lpPolicyPath;
+            public IntPtr hProfile;
+        }
+
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        public static extern bool LogonUser(string username, string? domain, string password, int logonType, int logonProvider, out IntPtr token);
+
+        [DllImport("userenv.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        public static extern bool LoadUserProfile(IntPtr token, ref PROFILEINFO profileInfo);
+
+        [DllImport("userenv.dll", SetLastError = true)]
+        public static extern bool UnloadUserProfile(IntPtr token, IntPtr hProfile);
+
+        [DllImport("advapi32.dll", SetLastError = true)]
+        public static extern bool IsTokenRestricted(IntPtr tokenHandle);
+
+        [DllImport("advapi32.dll", SetLastError = true)]
+        private static extern IntPtr GetSidSubAuthorityCount(IntPtr pSid);
+
+        [DllImport("advapi32.dll", SetLastError = true)]
+        private static extern IntPtr GetSidSubAuthority(IntPtr pSid, uint nSubAuthority);
+
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        private static extern bool LookupPrivilegeName(string? systemName, ref LUID luid, StringBuilder name, ref int cchName);
+
+        [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        private static extern bool LookupPrivilegeValue(string? systemName, string name, out LUID luid);
+
+        [StructLayout(LayoutKind.Sequential)]
+        private struct TOKEN_PRIVILEGES
+        {
+            public uint PrivilegeCount;
+            public LUID_AND_ATTRIBUTES Privilege;
+        }
+
+        [DllImport("advapi32.dll", SetLastError = true)]
+        private static extern bool AdjustTokenPrivileges(IntPtr tokenHandle, bool disableAllPrivileges, ref TOKEN_PRIVILEGES newState, int bufferLength, out TOKEN_PRIVILEGES previousState, out int returnLength);
+
+        [DllImport("kernel32.dll")]
+        private static extern IntPtr GetCurrentProcess();
+
+        // Privileges such as SeShutdownPrivilege are held but disabled in admin tokens; this enables one
+        // on the process token for the duration of the action and then restores the previous state
+        public static void WithPrivilege(string privilegeName, Action action)
+        {
+            if (!OpenProcessToken(GetCurrentProcess(), TOKEN_ADJUST_PRIVILEGES | TOKEN_QUERY, out var hToken))
+                throw ServiceOperationException.FromLastError("Failed to open process token");
+
+            try
+            {
+                if (!LookupPrivilegeValue(null, privilegeName, out var luid))
+                    throw ServiceOperationException.FromLastError($"Failed to look up {privilegeName}");
+
+                var enable = new TOKEN_PRIVILEGES
+                {
+                    PrivilegeCount = 1,
+                    Privilege = new LUID_AND_ATTRIBUTES { Luid = luid, Attributes = SE_PRIVILEGE_ENABLED }
+                };
+                if (!AdjustTokenPrivileges(hToken, false, ref enable, Marshal.SizeOf<TOKEN_PRIVILEGES>(), out var previous, out _))
+                    throw ServiceOperationException.FromLastError($"Failed to enable {privilegeName}");
+                if (Marshal.GetLastWin32Error() == ERROR_NOT_ALL_ASSIGNED)
+                    throw new ServiceOperationException($"The current account does not hold {privilegeName}", ERROR_NOT_ALL_ASSIGNED);
+
+                try
+                {
+                    action();
+                }
+                finally
+                {
+                    // PrivilegeCount is 0 when the privilege was already enabled, which leaves it as is
+                    AdjustTokenPrivileges(hToken, false, ref previous, Marshal.SizeOf<TOKEN_PRIVILEGES>(), out _, out _);
+                }
+            }
+            finally
+            {
+                ProcessUtils.CloseHandle(hToken);
+            }
+        }
+
+        // Caller must close the returned handle with ProcessUtils.CloseHandle
+        public static IntPtr OpenProcessTokenForQuery(int pid)
+        {
+            IntPtr hProcess = ProcessUtils.OpenProcess(ProcessUtils.PROCESS_QUERY_LIMITED_INFORMATION, false, pid);
+            if (hProcess == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError($"Failed to open process {pid}");
+
+            try
+            {
+                if (!OpenProcessToken(hProcess, TOKEN_QUERY, out var hToken))
+                    throw ServiceOperationException.FromLastError("Failed to open process token");
+                return hToken;
+            }
+            finally
+            {
+                ProcessUtils.CloseHandle(hProcess);
+            }
+        }
+
+        // Runs GetTokenInformation with a correctly sized buffer; the buffer is only valid inside `read`
+        public static T QueryTokenInformation<T>(IntPtr hToken, int infoClass, Func<IntPtr, T> read)
+        {
+            GetTokenInformation(hToken, infoClass, IntPtr.Zero, 0, out int length);
+            if (length == 0)
+                throw ServiceOperationException.FromLastError("Failed to query token information");
+
+            IntPtr buffer = Marshal.AllocHGlobal(length);
+            try
+            {
+                if (!GetTokenInformation(hToken, infoClass, buffer, length, out _))
+                    throw ServiceOperationException.FromLastError("Failed to query token information");
+                return read(buffer);
+            }
+            finally
+            {
+                Marshal.FreeHGlobal(buffer);
+            }
+        }
+
+        // TOKEN_ELEVATION_TYPE: 1 default, 2 full, 3 limited
+        public static int GetElevationType(IntPtr hToken)
+        {
+            return QueryTokenInformation(hToken, TokenElevationTypeClass, Marshal.ReadInt32);
+        }
+
+        // TOKEN_MANDATORY_LABEL starts with a pointer to the integrity SID; its last sub-authority is the level
+        public static uint GetIntegrityLevel(IntPtr hToken)
+        {
+            return QueryTokenInformation(hToken, TokenIntegrityLevelClass, buffer =>
+            {
+                IntPtr sid = Marshal.ReadIntPtr(buffer);
+                byte count = Marshal.ReadByte(GetSidSubAuthorityCount(sid));
+                return unchecked((uint)Marshal.ReadInt32(GetSidSubAuthority(sid, (uint)(count - 1))));
+            });
+        }
+
+        public static uint GetMandatoryPolicy(IntPtr hToken)
+        {
+            return QueryTokenInformation(hToken, TokenMandatoryPolicyClass, buffer => unchecked((uint)Marshal.ReadInt32(buffer)));
+        }
+
+        public static List<(string Name, LUID Luid, uint Attributes)> GetPrivileges(IntPtr hToken)
+        {
+            return QueryTokenInformation(hToken, TokenPrivilegesClass, buffer =>
+            {
+                var result = new List<(string, LUID, uint)>();
+                int count = Marshal.ReadInt32(buffer);
+                int size = Marshal.SizeOf<LUID_AND_ATTRIBUTES>();
+
+                for (int i = 0; i < count; i++)
+                {
+                    var entry = Marshal.PtrToStructure<LUID_AND_ATTRIBUTES>(buffer + 4 + i * size);
+                    var luid = entry.Luid;
+                    var name = new StringBuilder(64);
+                    int length = name.Capacity;
+                    string privilegeName = LookupPrivilegeName(null, ref luid, name, ref length) ? name.ToString() : $"LUID {luid.HighPart:X}:{luid.LowPart:X}";
+                    result.Add((privilegeName, luid, entry.Attributes));
+                }
+                return result;
+            });
+        }
+    }
+}
@@ -0,0 +1,142 @@
+using System;
+using System.Runtime.InteropServices;
+using System.Security.Cryptography;
+using System.Security.Cryptography.X509Certificates;
+using Services.Core.Models;
+
+namespace Services.Core.Helpers
+{
+    public static class TrustUtils
+    {
+        private static Guid WINTRUST_ACTION_GENERIC_VERIFY_V2 = new("00AAC56B-CD44-11d0-8CC2-00C04FC295EE");
+        private const uint WTD_UI_NONE = 2;
+        private const uint WTD_REVOKE_NONE = 0;
+        private const uint WTD_CHOICE_FILE = 1;
+        private const uint WTD_STATEACTION_VERIFY = 1;
+        private const uint WTD_STATEACTION_CLOSE = 2;
+        private const uint WTD_CACHE_ONLY_URL_RETRIEVAL = 0x00001000;
+
+        [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+        private struct WINTRUST_FILE_INFO
+        {
+            public uint cbStruct;
+            public string pcwszFilePath;
+            public IntPtr hFile;
+            public IntPtr pgKnownSubject;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        private struct WINTRUST_DATA
+        {
+            public uint cbStruct;
+            public IntPtr pPolicyCallbackData;
+            public IntPtr pSIPClientData;
+            public uint dwUIChoice;
+            public uint fdwRevocationChecks;
+            public uint dwUnionChoice;
+            public IntPtr pFile;
+            public uint dwStateAction;
+            public IntPtr hWVTStateData;
+            public IntPtr pwszURLReference;
+            public uint dwProvFlags;
+            public uint dwUIContext;
+            public IntPtr pSignatureSettings;
+        }
+
+        [DllImport("wintrust.dll", CharSet = CharSet.Unicode)]
+        private static extern int WinVerifyTrust(IntPtr hwnd, ref Guid pgActionID, ref WINTRUST_DATA pWVTData);
+
+        private static readonly IntPtr CERT_CHAIN_POLICY_MICROSOFT_ROOT = (IntPtr)7;
+
+        [StructLayout(LayoutKind.Sequential)]
+        private struct CERT_CHAIN_POLICY_PARA
+        {
+            public uint cbSize;
+            public uint dwFlags;
+            public IntPtr pvExtraPolicyPara;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        private struct CERT_CHAIN_POLICY_STATUS
+        {
+            public uint cbSize;
+            public uint dwError;
+            public int lChainIndex;
+            public int lElementIndex;
+            public IntPtr pvExtraPolicyStatus;
+        }
+
+        [DllImport("crypt32.dll", SetLastError = true)]
+        [return: MarshalAs(UnmanagedType.Bool)]
+        private static extern bool CertVerifyCertificateChainPolicy(IntPtr pszPolicyOID, IntPtr pChainContext, ref CERT_CHAIN_POLICY_PARA pPolicyPara, ref CERT_CHAIN_POLICY_STATUS pPolicyStatus);
+
+        // Only embedded Authenticode signatures are considered; catalog-signed system binaries report as unsigned.
+        // Revocation is not checked so the call never blocks on the network.
+        public static TrustLevel GetFileTrustLevel(string path)
+        {
+            var level = new TrustLevel();
+
+            var fileInfo = new WINTRUST_FILE_INFO
+            {
+                cbStruct = (uint)Marshal.SizeOf<WINTRUST_FILE_INFO>(),
+                pcwszFilePath = path
+            };
+            IntPtr pFile = Marshal.AllocHGlobal(Marshal.SizeOf<WINTRUST_FILE_INFO>());
+            try
+            {
+                Marshal.StructureToPtr(fileInfo, pFile, false);
+                var data = new WINTRUST_DATA
+                {
+                    cbStruct = (uint)Marshal.SizeOf<WINTRUST_DATA>(),
+                    dwUIChoice = WTD_UI_NONE,
+                    fdwRevocationChecks = WTD_REVOKE_NONE,
+                    dwUnionChoice = WTD_CHOICE_FILE,
+                    pFile = pFile,
+                    dwStateAction = WTD_STATEACTION_VERIFY,
+                    dwProvFlags = WTD_CACHE_ONLY_URL_RETRIEVAL
+                };
+
+                level.IsTrusted = WinVerifyTrust(IntPtr.Zero, ref WINTRUST_ACTION_GENERIC_VERIFY_V2, ref data) == 0;
+
+                data.dwStateAction = WTD_STATEACTION_CLOSE;
+                WinVerifyTrust(IntPtr.Zero, ref WINTRUST_ACTION_GENERIC_VERIFY_V2, ref data);
+            }
+            finally
+            {
+                Marshal.DestroyStructure<WINTRUST_FILE_INFO>(pFile);
+                Marshal.FreeHGlobal(pFile);
+            }
+
+            try
+            {
+                using var cert = new X509Certificate2(X509Certificate.CreateFromSignedFile(path));
+                level.IsSigned = true;
+                level.Publisher = cert.GetNameInfo(X509NameType.SimpleName, false);
+                level.SubjectName = cert.Subject;
+                level.IssuerName = cert.Issuer;
+                level.ValidFrom = cert.NotBefore;
+                level.ValidTo = cert.NotAfter;
+                level.IsExpired = DateTime.Now > cert.NotAfter;
+                // The subject is chosen by whoever made the certificate, so only the chain root counts
+                level.IsMicrosoftSigned = level.IsTrusted && ChainsToMicrosoftRoot(cert);
+            }
+            catch (CryptographicException)
+            {
+                // No embedded signature
+            }
+
+            return level;
+        }
+
+        private static bool ChainsToMicrosoftRoot(X509Certificate2 cert)
+        {
+            using var chain = new X509Chain();
+            chain.ChainPolicy.RevocationMode = X509RevocationMode.NoCheck;
+            chain.Build(cert);
+
+            var para = new CERT_CHAIN_POLICY_PARA { cbSize = (uint)Marshal.SizeOf<CERT_CHAIN_POLICY_PARA>() };
+            var status = new CERT_CHAIN_POLICY_STATUS { cbSize = (uint)Marshal.SizeOf<CERT_CHAIN_POLICY_STATUS>() };
+            return CertVerifyCertificateChainPolicy(CERT_CHAIN_POLICY_MICROSOFT_ROOT, chain.ChainContext, ref para, ref status) && status.dwError == 0;
+        }
+    }
+}
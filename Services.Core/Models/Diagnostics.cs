@@ -0,0 +1,367 @@
+using System;
+using System.Collections.Generic;
+
+namespace Services.Core.Models
+{
+    public class StartupError
+    {
+        public DateTime Timestamp { get; set; }
+        public uint EventId { get; set; }
+        public string Message { get; set; } = string.Empty;
+        public int ExitCode { get; set; }
+    }
+
+    public class ServiceEventLogEntry
+    {
+        public DateTime TimeGenerated { get; set; }
+        public uint EventId { get; set; }
+        public string Level { get; set; } = string.Empty;
+        public string Source { get; set; } = string.Empty;
+        public string Message { get; set; } = string.Empty;
+    }
+
+    public class ServiceWarningEventArgs : EventArgs
+    {
+        public string ServiceId { get; set; } = string.Empty;
+        public string Kind { get; set; } = string.Empty;
+        public string Message { get; set; } = string.Empty;
+    }
+
+    public class RestartStats
+    {
+        public string ServiceId { get; set; } = string.Empty;
+        public int RestartCount { get; set; }
+        public DateTime? LastRestartTime { get; set; }
+        public int TotalCrashes { get; set; }
+        public TimeSpan? MeanTimeBetweenCrashes { get; set; }
+    }
+
+    public class TokenInfo
+    {
+        public string Username { get; set; } = string.Empty;
+        public string Domain { get; set; } = string.Empty;
+        public string SID { get; set; } = string.Empty;
+        public bool IsLocalSystem { get; set; }
+        public bool IsLocalService { get; set; }
+        public bool IsNetworkService { get; set; }
+        public List<string> Groups { get; set; } = new();
+        public List<string> Privileges { get; set; } = new();
+    }
+
+    public class EffectiveTokenSummary
+    {
+        // "network", "interactive", "batch", "service" or "unknown"
+        public string LogonType { get; set; } = string.Empty;
+        // "full", "limited" or "default"
+        public string Elevation { get; set; } = string.Empty;
+        // "untrusted", "low", "medium", "high" or "system"
+        public string MandatoryLabel { get; set; } = string.Empty;
+        public bool IsRestricted { get; set; }
+        // RID of the integrity SID, e.g. 0x3000 for high
+        public uint IntegrityLevel { get; set; }
+        // TOKEN_MANDATORY_POLICY flags
+        public bool NoWriteUp { get; set; }
+        public bool NewProcessMin { get; set; }
+    }
+
+    public class TokenPrivilege
+    {
+        public string Name { get; set; } = string.Empty;
+        public string LUID { get; set; } = string.Empty;
+        public string Attributes { get; set; } = string.Empty;
+    }
+
+    public class UserProfileStatus
+    {
+        public string Username { get; set; } = string.Empty;
+        public bool ProfileLoaded { get; set; }
+        public string ProfilePath { get; set; } = string.Empty;
+        public bool IsLocalProfile { get; set; }
+    }
+
+    public class ServiceStartOrderInfo
+    {
+        public string ServiceId { get; set; } = string.Empty;
+        public string ServiceName { get; set; } = string.Empty;
+        public string StartType { get; set; } = string.Empty;
+        public int EstimatedStartOrder { get; set; }
+        public List<string> Dependencies { get; set; } = new();
+    }
+
+    public class LogStats
+    {
+        public string LogPath { get; set; } = string.Empty;
+        public long FileSizeBytes { get; set; }
+        public int TotalLines { get; set; }
+        public int ErrorLines { get; set; }
+        public int WarningLines { get; set; }
+        public DateTime LastModified { get; set; }
+        // Error lines per hour over the last 24 hours
+        public double ErrorRate { get; set; }
+    }
+
+    public class RegistryActivityEvent
+    {
+        public DateTime Timestamp { get; set; }
+        // "open", "create", "set" or "delete"
+        public string Operation { get; set; } = string.Empty;
+        public string KeyPath { get; set; } = string.Empty;
+        public string ValueName { get; set; } = string.Empty;
+    }
+
+    public class FileActivityEvent
+    {
+        public DateTime Timestamp { get; set; }
+        // "read", "write", "create", "delete" or "rename"
+        public string Operation { get; set; } = string.Empty;
+        public string FilePath { get; set; } = string.Empty;
+        public ulong Bytes { get; set; }
+    }
+
+    public class TrustLevel
+    {
+        public bool IsTrusted { get; set; }
+        public bool IsSigned { get; set; }
+        public string Publisher { get; set; } = string.Empty;
+        public string SubjectName { get; set; } = string.Empty;
+        public string IssuerName { get; set; } = string.Empty;
+        public DateTime ValidFrom { get; set; }
+        public DateTime ValidTo { get; set; }
+        public bool IsExpired { get; set; }
+        public bool IsMicrosoftSigned { get; set; }
+        public bool IsUnsigned => !IsSigned;
+    }
+
+    public class WrapperVersionInfo
+    {
+        public string WrapperPath { get; set; } = string.Empty;
+        public string WrapperVersion { get; set; } = string.Empty;
+        public string CurrentVersion { get; set; } = string.Empty;
+        public bool NeedsUpdate { get; set; }
+        // The service points at a different copy of the wrapper; not an update on its own
+        public bool IsPathMismatch { get; set; }
+    }
+
+    public class PortOccupant
+    {
+        public int Pid { get; set; }
+        public string ProcessName { get; set; } = string.Empty;
+        public string? ServiceName { get; set; }
+        public bool IsManaged { get; set; }
+    }
+
+    public class RegistryValueInfo
+    {
+        // RegistryValueKind name, e.g. "DWord" or "MultiString"
+        public string Kind { get; set; } = string.Empty;
+        public object? Value { get; set; }
+    }
+
+    public class StartupImpactInfo
+    {
+        public string ServiceId { get; set; } = string.Empty;
+        public string ServiceName { get; set; } = string.Empty;
+        public string StartType { get; set; } = string.Empty;
+        public int StartOrder { get; set; }
+        public int EstimatedStartDelayMs { get; set; }
+    }
+
+    public class FaultBucket
+    {
+        public string BucketId { get; set; } = string.Empty;
+        public DateTime Timestamp { get; set; }
+        public string CrashType { get; set; } = string.Empty;
+        public string ExeVersion { get; set; } = string.Empty;
+    }
+
+    public class DependencyStatus
+    {
+        public bool AllAvailable { get; set; }
+        public List<DependencyInfo> Dependencies { get; set; } = new();
+    }
+
+    public class DependencyInfo
+    {
+        public string ServiceName { get; set; } = string.Empty;
+        public string Status { get; set; } = string.Empty;
+        public bool IsAvailable { get; set; }
+    }
+
+    public class DependencyValidation
+    {
+        public string ServiceName { get; set; } = string.Empty;
+        public bool Exists { get; set; }
+        public string Status { get; set; } = string.Empty;
+        public bool IsAvailable { get; set; }
+    }
+
+    public class DependencyNode
+    {
+        public string ServiceName { get; set; } = string.Empty;
+        public string DisplayName { get; set; } = string.Empty;
+        public bool IsManaged { get; set; }
+        public string Status { get; set; } = string.Empty;
+        public List<string> Children { get; set; } = new();
+    }
+
+    public class ExitCodeEntry
+    {
+        public DateTime Timestamp { get; set; }
+        public int ExitCode { get; set; }
+        public bool ExitedNormally { get; set; }
+    }
+
+    public class NetworkBandwidthSample
+    {
+        public string ServiceId { get; set; } = string.Empty;
+        public ulong BytesReceivedPerSec { get; set; }
+        public ulong BytesSentPerSec { get; set; }
+        public DateTime Timestamp { get; set; }
+    }
+
+    public class CPUSample
+    {
+        public DateTime Timestamp { get; set; }
+        public double CpuPercent { get; set; }
+    }
+
+    public class MemorySample
+    {
+        public DateTime Timestamp { get; set; }
+        public double WorkingSetMB { get; set; }
+    }
+
+    public class PerformanceHistory
+    {
+        public string ServiceId { get; set; } = string.Empty;
+        public List<CPUSample> Cpu { get; set; } = new();
+        public List<MemorySample> Memory { get; set; } = new();
+    }
+
+    public class ServiceMemorySnapshot
+    {
+        public string ServiceId { get; set; } = string.Empty;
+        public string ServiceName { get; set; } = string.Empty;
+        public int Pid { get; set; }
+        public double WorkingSetMB { get; set; }
+    }
+
+    public class ServiceDiskUsage
+    {
+        public string ServiceId { get; set; } = string.Empty;
+        public long LogFileSizeBytes { get; set; }
+        public long WorkingDirSizeBytes { get; set; }
+        public long RegistryKeySizeBytes { get; set; }
+    }
+
+    public class FirewallRule
+    {
+        public string Name { get; set; } = string.Empty;
+        public string Direction { get; set; } = string.Empty;
+        public string Protocol { get; set; } = string.Empty;
+        public string LocalPorts { get; set; } = string.Empty;
+        public string Action { get; set; } = string.Empty;
+    }
+
+    public class PageFaultSample
+    {
+        public DateTime Timestamp { get; set; }
+        public uint PageFaultCount { get; set; }
+        public double PageFaultRate { get; set; }
+    }
+
+    public class ServiceReportEntry
+    {
+        public string Id { get; set; } = string.Empty;
+        public string Name { get; set; } = string.Empty;
+        public string? Alias { get; set; }
+        public string Status { get; set; } = string.Empty;
+        public int Pid { get; set; }
+        public long UptimeSeconds { get; set; }
+        public int RestartCount { get; set; }
+        public double MemoryMb { get; set; }
+        public double CpuPercent { get; set; }
+        public string? LastError { get; set; }
+        public long LogSizeBytes { get; set; }
+    }
+
+    public class WERPolicy
+    {
+        public bool Enabled { get; set; }
+        // Machine-wide; reported here but only changed through SetWERDontShowUI
+        public bool DontShowUI { get; set; }
+        // "mini", "heap" or "full"
+        public string DumpType { get; set; } = "mini";
+        public string DumpFolder { get; set; } = string.Empty;
+        public int MaxDumps { get; set; } = 10;
+    }
+
+    public class JobObjectInfo
+    {
+        public uint TotalProcesses { get; set; }
+        public uint ActiveProcesses { get; set; }
+        public uint TotalTerminations { get; set; }
+        public ulong PeakMemoryBytes { get; set; }
+        public ulong TotalUserTimeNs { get; set; }
+    }
+
+    public class DNSTestResult
+    {
+        public string Hostname { get; set; } = string.Empty;
+        public List<string> Addresses { get; set; } = new();
+        public string? Error { get; set; }
+        public double DurationMs { get; set; }
+        public DateTime Timestamp { get; set; }
+    }
+
+    public class SessionInfo
+    {
+        public uint SessionId { get; set; }
+        public string Username { get; set; } = string.Empty;
+        public string Domain { get; set; } = string.Empty;
+        // WTS connect state: "Active", "Disconnected", "Listen", ...
+        public string SessionState { get; set; } = string.Empty;
+    }
+
+    public class RunningServiceSnapshot
+    {
+        public string ServiceId { get; set; } = string.Empty;
+        // Same as ServiceId for services created by this app; kept separate for correlation with SCM events
+        public string SCMServiceName { get; set; } = string.Empty;
+        // Wrapper process ID as reported by the SCM
+        public int Pid { get; set; }
+        public DateTime StartTime { get; set; }
+    }
+
+    public class CertificateInfo
+    {
+        // "LocalMachine\MY", "<SID>\ROOT", ...
+        public string Store { get; set; } = string.Empty;
+        public string SubjectName { get; set; } = string.Empty;
+        public string Issuer { get; set; } = string.Empty;
+        public DateTime ValidFrom { get; set; }
+        public DateTime ValidTo { get; set; }
+        public bool IsExpired { get; set; }
+        // Negative once expired
+        public int DaysUntilExpiry { get; set; }
+        public string Thumbprint { get; set; } = string.Empty;
+    }
+
+    // Measured by the manager around its own start/stop calls, kept in memory only
+    public class TimingDiagnostics
+    {
+        public long StartLatencyMs { get; set; }
+        public long StopLatencyMs { get; set; }
+        // Over the most recent starts
+        public long AvgStartLatencyMs { get; set; }
+        public long MaxStartLatencyMs { get; set; }
+        public int StartSamples { get; set; }
+    }
+
+    public class PidHistoryEntry
+    {
+        public int Pid { get; set; }
+        public DateTime StartTime { get; set; }
+        public DateTime? StopTime { get; set; }
+    }
+}
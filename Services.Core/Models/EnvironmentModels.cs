@@ -0,0 +1,83 @@
+using System;
+using System.Collections.Generic;
+
+namespace Services.Core.Models
+{
+    public class EnvImportResult
+    {
+        public int Added { get; set; }
+        public int Updated { get; set; }
+        public int Skipped { get; set; }
+    }
+
+    public class EnvironmentPreset
+    {
+        public string Name { get; set; } = string.Empty;
+        public string Description { get; set; } = string.Empty;
+        // Scope the preset was saved for; LoadPreset can target a different one
+        public string Scope { get; set; } = "user";
+        public Dictionary<string, string> Variables { get; set; } = new(StringComparer.OrdinalIgnoreCase);
+        public List<string> PathEntries { get; set; } = new();
+    }
+
+    public class EnvironmentValueChange
+    {
+        public string RegistryValue { get; set; } = string.Empty;
+        public string SessionValue { get; set; } = string.Empty;
+    }
+
+    // Differences between this process's environment and what the registry would produce for a new session
+    public class EnvironmentDiff
+    {
+        public Dictionary<string, string> Added { get; set; } = new(StringComparer.OrdinalIgnoreCase);
+        public Dictionary<string, string> Removed { get; set; } = new(StringComparer.OrdinalIgnoreCase);
+        public Dictionary<string, EnvironmentValueChange> Changed { get; set; } = new(StringComparer.OrdinalIgnoreCase);
+    }
+
+    public class ValidationWarning
+    {
+        public string Code { get; set; } = string.Empty;
+        public string Message { get; set; } = string.Empty;
+    }
+
+    // Returned (not thrown) when a system variable is shadowed by a user variable of the same name
+    public class NameCollisionWarning
+    {
+        public string VarName { get; set; } = string.Empty;
+        public string UserValue { get; set; } = string.Empty;
+        public string Message { get; set; } = string.Empty;
+    }
+
+    public class EnvironmentChangeEntry
+    {
+        public DateTime Timestamp { get; set; }
+        // "add", "modify" or "delete"
+        public string Operation { get; set; } = string.Empty;
+        public string VarName { get; set; } = string.Empty;
+        public string OldValue { get; set; } = string.Empty;
+        public string NewValue { get; set; } = string.Empty;
+        public string ChangedBy { get; set; } = string.Empty;
+    }
+
+    public class EnvironmentInheritanceEntry
+    {
+        public string Name { get; set; } = string.Empty;
+        public string Value { get; set; } = string.Empty;
+        public string? InheritedValue { get; set; }
+        // "inherited-only", "override" or "added-by-service"
+        public string Source { get; set; } = string.Empty;
+    }
+
+    public class EnvironmentInheritanceReport
+    {
+        public string ServiceId { get; set; } = string.Empty;
+        public List<EnvironmentInheritanceEntry> Variables { get; set; } = new();
+    }
+
+    public class ExpansionStep
+    {
+        public string VariableName { get; set; } = string.Empty;
+        public string RawValue { get; set; } = string.Empty;
+        public string ExpandedValue { get; set; } = string.Empty;
+    }
+}
@@ -0,0 +1,54 @@
+using System;
+using System.Collections.Generic;
+using System.ComponentModel;
+using System.IO;
+using System.Runtime.InteropServices;
+
+namespace Services.Core.Models
+{
+    public class CyclicDependencyException : Exception
+    {
+        public IReadOnlyList<string> Cycle { get; }
+
+        public CyclicDependencyException(IReadOnlyList<string> cycle)
+            : base($"Cyclic service dependency detected: {string.Join(" -> ", cycle)}")
+        {
+            Cycle = cycle;
+        }
+    }
+
+    public class WorkingDirectoryNotFoundException : DirectoryNotFoundException
+    {
+        public string ServiceId { get; }
+        public string WorkingDir { get; }
+
+        public WorkingDirectoryNotFoundException(string serviceId, string workingDir)
+            : base($"Working directory of service {serviceId} does not exist: {workingDir}")
+        {
+            ServiceId = serviceId;
+            WorkingDir = workingDir;
+        }
+    }
+
+    // Win32 failure with the system message text, e.g. "Failed to start service: The service did not respond ... (1053)"
+    public class ServiceOperationException : Win32Exception
+    {
+        public string Operation { get; }
+
+        public ServiceOperationException(string operation, int errorCode)
+            : base(errorCode, $"{operation}: {GetWindowsErrorMessage(errorCode)} ({errorCode})")
+        {
+            Operation = operation;
+        }
+
+        public static ServiceOperationException FromLastError(string operation)
+        {
+            return new ServiceOperationException(operation, Marshal.GetLastWin32Error());
+        }
+
+        public static string GetWindowsErrorMessage(int errorCode)
+        {
+            return Marshal.GetPInvokeErrorMessage(errorCode).TrimEnd('\r', '\n', ' ', '.');
+        }
+    }
+}
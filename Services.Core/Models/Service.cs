@@ -1,4 +1,5 @@
 using System;
+using System.Collections.Generic;
 using System.ComponentModel;
 using System.Runtime.CompilerServices;
 
@@ -8,6 +9,10 @@ namespace Services.Core.Models
     {
         private string _status = "未知";
         private int _pid;
+        private bool _handleLeakWarning;
+        private int _dailyStartCount;
+        private bool _debugMode;
+        private string? _iconPath;
 
         public string Id { get; set; } = string.Empty;
         public string Name { get; set; } = string.Empty;
@@ -41,6 +46,94 @@ namespace Services.Core.Models
             }
         }
 
+        public string? IconPath
+        {
+            get => _iconPath;
+            set
+            {
+                if (_iconPath != value)
+                {
+                    _iconPath = value;
+                    OnPropertyChanged();
+                    OnPropertyChanged(nameof(DisplayIconPath));
+                }
+            }
+        }
+
+        // Falls back to the executable so its embedded icon is shown
+        public string DisplayIconPath => string.IsNullOrEmpty(IconPath) ? ExePath : IconPath;
+
+        // Human-friendly ID used in logs and reports; the SCM name stays the real ID
+        public string? Alias { get; set; }
+        public uint HandleThreshold { get; set; }
+        public uint PageFaultThreshold { get; set; }
+        public ulong AffinityMask { get; set; }
+        public ulong MemoryLimitMB { get; set; }
+        public ServiceControlPermissions? Permissions { get; set; }
+        public List<PidHistoryEntry> PidHistory { get; set; } = new();
+        public List<ExitCodeEntry> ExitCodeHistory { get; set; } = new();
+        public bool WrapperNeedsUpdate { get; set; }
+        // Variables the wrapper sets for the target process, on top of what it inherits
+        public Dictionary<string, string>? Environment { get; set; }
+        // Signature check of ExePath, computed on load
+        public TrustLevel? Trust { get; set; }
+
+        public bool HandleLeakWarning
+        {
+            get => _handleLeakWarning;
+            set
+            {
+                if (_handleLeakWarning != value)
+                {
+                    _handleLeakWarning = value;
+                    OnPropertyChanged();
+                }
+            }
+        }
+
+        // More starts than this in one day usually means the process is crash looping
+        public const int CrashLoopDailyStarts = 10;
+
+        // Process starts recorded by the wrapper on DailyStartCountDate (yyyy-MM-dd)
+        public int DailyStartCount
+        {
+            get => _dailyStartCount;
+            set
+            {
+                if (_dailyStartCount != value)
+                {
+                    _dailyStartCount = value;
+                    OnPropertyChanged();
+                    OnPropertyChanged(nameof(IsCrashLooping));
+                }
+            }
+        }
+
+        public string? DailyStartCountDate { get; set; }
+        public bool IsCrashLooping => DailyStartCount > CrashLoopDailyStarts;
+
+        // The wrapper adds DEBUG entries (environment, command line, exception stacks) to the log
+        public bool DebugMode
+        {
+            get => _debugMode;
+            set
+            {
+                if (_debugMode != value)
+                {
+                    _debugMode = value;
+                    OnPropertyChanged();
+                }
+            }
+        }
+
+        // Zero means the default wait of 30 seconds (stop falls back to the preshutdown timeout first)
+        public TimeSpan StartTimeout { get; set; }
+        public TimeSpan StopTimeout { get; set; }
+        public TimingDiagnostics? Timing { get; set; }
+        public string? LastRestartReason { get; set; }
+        // "user", "crash", "scheduler", "health-check" or "wrapper-policy"
+        public string? LastRestartInitiator { get; set; }
+
         public bool AutoStart { get; set; }
         public bool AutoRestart { get; set; }
         public DateTime CreatedAt { get; set; }
@@ -53,6 +146,14 @@ namespace Services.Core.Models
         }
     }
 
+    public class ServiceControlPermissions
+    {
+        public bool CanStart { get; set; }
+        public bool CanStop { get; set; }
+        public bool CanDelete { get; set; }
+        public bool CanChangeConfig { get; set; }
+    }
+
     public class ServiceConfig
     {
         public string Name { get; set; } = string.Empty;
@@ -61,11 +162,114 @@ namespace Services.Core.Models
         public string? WorkingDir { get; set; }
         public bool AutoRestart { get; set; }
         public ServiceStartupType StartupType { get; set; } = ServiceStartupType.Auto;
+        public List<string>? Dependencies { get; set; }
+        // How long start/stop calls wait for the state change; zero uses the 30 second default
+        public TimeSpan StartTimeout { get; set; }
+        public TimeSpan StopTimeout { get; set; }
+        // Applied by the wrapper on top of the inherited environment
+        public Dictionary<string, string>? Environment { get; set; }
+        // Fixed file for stdout/stderr; null keeps timestamped files in the shared log directory
+        public string? LogFile { get; set; }
+    }
+
+    public class WindowsServiceXml
+    {
+        public string Executable { get; set; } = string.Empty;
+        public string? Arguments { get; set; }
+        public string? WorkingDirectory { get; set; }
+        public string DisplayName { get; set; } = string.Empty;
+        public string? Alias { get; set; }
+        public string? Description { get; set; }
+        public string StartType { get; set; } = string.Empty;
+        public ServiceFailureActions? RecoveryActions { get; set; }
+    }
+
+    public class RestartPolicy
+    {
+        public int MaxAttempts { get; set; } = 5;
+        public TimeSpan InitialDelay { get; set; } = TimeSpan.FromSeconds(5);
+        public double BackoffFactor { get; set; } = 2.0;
+        public TimeSpan MaxDelay { get; set; } = TimeSpan.FromSeconds(80);
+        // The attempt counter resets once the process has run this long without crashing
+        public TimeSpan ResetAfter { get; set; } = TimeSpan.FromMinutes(10);
+
+        // Delay before the given 1-based attempt
+        public TimeSpan GetDelay(int attempt)
+        {
+            double ms = InitialDelay.TotalMilliseconds * Math.Pow(BackoffFactor, Math.Max(attempt - 1, 0));
+            return TimeSpan.FromMilliseconds(Math.Min(ms, MaxDelay.TotalMilliseconds));
+        }
+    }
+
+    public class HealthCheckConfig
+    {
+        // "http", "tcp" or "exec"
+        public string Type { get; set; } = "http";
+        // URL, host:port or command line, depending on Type
+        public string Target { get; set; } = string.Empty;
+        public int IntervalSeconds { get; set; } = 30;
+        public int TimeoutSeconds { get; set; } = 5;
+        // Consecutive failures before the wrapper restarts the process
+        public int UnhealthyThreshold { get; set; } = 3;
+    }
+
+    // Everything the wrapper reads from Parameters at start. Stdout and stderr both go to the rotated
+    // service log.
+    public class WrapperConfig
+    {
+        public bool AutoRestart { get; set; }
+        public RestartPolicy RestartPolicy { get; set; } = new();
+        public int StopGracePeriodSeconds { get; set; } = 5;
+        public int LogMaxSizeMB { get; set; }
+        // Rotated generations kept as <name>.1.log ... <name>.N.log
+        public int LogMaxFiles { get; set; } = 1;
+        public string? LogFile { get; set; }
+        public ulong AffinityMask { get; set; }
+        // A ProcessPriorityClass name such as "BelowNormal"; null keeps the default
+        public string? ProcessPriority { get; set; }
+        public HealthCheckConfig? HealthCheck { get; set; }
+        public Dictionary<string, string>? Environment { get; set; }
+    }
+
+    public class ServiceConfigTemplate
+    {
+        public string Name { get; set; } = string.Empty;
+        public string? Args { get; set; }
+        public int MaxRestarts { get; set; } = 5;
+        public int RestartDelaySeconds { get; set; } = 5;
+        public int LogMaxSizeMB { get; set; }
+        public int StopGracePeriodSeconds { get; set; } = 5;
+    }
+
+    public class TaskInfo
+    {
+        public string TaskName { get; set; } = string.Empty;
+        public string TaskPath { get; set; } = string.Empty;
+    }
+
+    public class ServiceSchedule
+    {
+        public string StartCron { get; set; } = string.Empty;
+        public string StopCron { get; set; } = string.Empty;
+        public bool Enabled { get; set; }
+    }
+
+    public class ServiceResourceLimits
+    {
+        public ulong MemoryLimitMB { get; set; }
+        public ulong AffinityMask { get; set; }
+        public uint HandleThreshold { get; set; }
+        public uint PageFaultThreshold { get; set; }
+        public int MaxRestarts { get; set; } = 5;
+        public int RestartCooldownSeconds { get; set; } = 600;
+        public int StopGracePeriodSeconds { get; set; } = 5;
+        public int LogMaxSizeMB { get; set; }
     }
 
     public enum ServiceStartupType
     {
         Auto = 2,
-        Manual = 3
+        Manual = 3,
+        Disabled = 4
     }
 }
@@ -0,0 +1,77 @@
+using System;
+using System.Collections.Generic;
+
+namespace Services.Core.Models
+{
+    public class ServiceDetails
+    {
+        public Service Service { get; set; } = new();
+        public string SCMStartType { get; set; } = string.Empty;
+        public string SCMDescription { get; set; } = string.Empty;
+        public string SCMBinaryPath { get; set; } = string.Empty;
+        public List<string> SCMDependencies { get; set; } = new();
+        public string SCMRunAsUser { get; set; } = string.Empty;
+        public ServiceFailureActions? SCMFailureActions { get; set; }
+        public string SCMSIDType { get; set; } = string.Empty;
+        public List<string> SCMRequiredPrivileges { get; set; } = new();
+        public BinaryInfo? BinaryInfo { get; set; }
+        public ServiceMetrics? ProcessMetrics { get; set; }
+    }
+
+    public class ServiceFailureActions
+    {
+        public int ResetPeriodSeconds { get; set; }
+        public string? Command { get; set; }
+        public List<ServiceFailureAction> Actions { get; set; } = new();
+    }
+
+    public class ServiceFailureAction
+    {
+        public string Type { get; set; } = string.Empty;
+        public TimeSpan Delay { get; set; }
+    }
+
+    // Values match SC_ACTION_TYPE
+    public enum RecoveryActionType
+    {
+        None = 0,
+        Restart = 1,
+        Reboot = 2,
+        RunProgram = 3
+    }
+
+    public class ServiceRecoveryConfig
+    {
+        public RecoveryActionType FirstFailureAction { get; set; } = RecoveryActionType.Restart;
+        public RecoveryActionType SecondFailureAction { get; set; } = RecoveryActionType.Restart;
+        public RecoveryActionType SubsequentFailureAction { get; set; } = RecoveryActionType.Restart;
+        public int ResetPeriodSeconds { get; set; } = 86400;
+        // Delay before every action
+        public int RestartDelayMilliseconds { get; set; } = 60000;
+        // Run for RunProgram actions
+        public string? FailureCommand { get; set; }
+    }
+
+    public class BinaryInfo
+    {
+        public string Path { get; set; } = string.Empty;
+        public long SizeBytes { get; set; }
+        public DateTime LastModified { get; set; }
+        public string? FileVersion { get; set; }
+        public string? ProductVersion { get; set; }
+        public string? ProductName { get; set; }
+        public string? CompanyName { get; set; }
+        public string? FileDescription { get; set; }
+    }
+
+    public class ServiceMetrics
+    {
+        public int Pid { get; set; }
+        public double WorkingSetMB { get; set; }
+        public double PrivateMemoryMB { get; set; }
+        public int HandleCount { get; set; }
+        public int ThreadCount { get; set; }
+        public DateTime StartTime { get; set; }
+        public TimeSpan TotalProcessorTime { get; set; }
+    }
+}
@@ -1,11 +1,16 @@
 using System;
 using System.Diagnostics;
+using System.Globalization;
 using System.IO;
+using System.Linq;
+using System.Net;
 using System.ServiceProcess;
+using System.Text.Json;
 using System.Threading;
 using System.Threading.Tasks;
 using Microsoft.Win32;
 using Services.Core.Helpers;
+using Services.Core.Models;
 
 namespace Services.Core.Services
 {
@@ -13,18 +18,55 @@ namespace Services.Core.Services
     {
         private Process? _process;
         private string _serviceName;
+        private string? _alias;
         private AsyncLogger? _logger;
+        private JobObject? _job;
         private bool _autoRestart = false;
-        private int _restartDelayMs = 5000;
         private bool _isStopping = false;
         private int _restartCount = 0;
-        private DateTime _lastRestartTime = DateTime.MinValue;
-        private const int MaxRestarts = 5;
+        private DateTime _processStartTime = DateTime.MinValue;
+        private RestartPolicy _restartPolicy = new();
+        private int _stopGracePeriodSeconds = 5;
+        private int _logMaxSizeMB = 0;
+        private int _logMaxFiles = 1;
+        private string? _logFile;
+        // A fixed log file is appended to across runs, so it rotates even when no size was configured
+        private const int DefaultFixedLogMaxSizeMB = 10;
+        private volatile bool _debugMode;
+        private Timer? _requestTimer;
+        private static readonly TimeSpan RequestPollInterval = TimeSpan.FromSeconds(10);
+        private HealthCheckConfig? _healthCheck;
+        private Timer? _healthTimer;
+        private int _healthCheckRunning;
+        private int _healthFailures;
+        private bool _healthRestartPending;
+        private string _healthRestartReason = "";
+        private volatile bool _isPaused;
 
         public EmbeddedServiceWrapper(string serviceName)
         {
             _serviceName = serviceName;
             ServiceName = serviceName;
+            CanPauseAndContinue = true;
+            LoadAlias();
+        }
+
+        // "alias (WSM_App_123)" when an alias is set, otherwise the SCM name
+        private string LogName => _alias != null ? $"{_alias} ({_serviceName})" : _serviceName;
+
+        // The manager registers the alias as an Application event source when it is set
+        private void LoadAlias()
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters");
+                if (key?.GetValue("CustomAlias") is string alias && alias.Length > 0)
+                {
+                    _alias = alias;
+                    if (EventLog.SourceExists(alias)) EventLog.Source = alias;
+                }
+            }
+            catch { }
         }
 
         protected override void OnStart(string[] args)
@@ -33,9 +75,16 @@ namespace Services.Core.Services
             {
                 var config = LoadConfig();
                 _autoRestart = LoadAutoRestart();
+                LoadLimits();
 
                 InitLogger();
                 StartTargetProcess(config);
+                _requestTimer = new Timer(_ => PollRequests(), null, RequestPollInterval, RequestPollInterval);
+                if (_healthCheck != null)
+                {
+                    var interval = TimeSpan.FromSeconds(_healthCheck.IntervalSeconds);
+                    _healthTimer = new Timer(_ => RunHealthCheck(), null, interval, interval);
+                }
             }
             catch (Exception ex)
             {
@@ -47,10 +96,26 @@ namespace Services.Core.Services
 
         private void InitLogger()
         {
-            var logDir = Path.Combine(Environment.GetFolderPath(Environment.SpecialFolder.CommonApplicationData), "windows_service_logs");
-            Directory.CreateDirectory(logDir);
-            var logFile = Path.Combine(logDir, $"{_serviceName}_{DateTime.Now:yyyyMMdd_HHmmss}.log");
-            _logger = new AsyncLogger(logFile);
+            if (_logFile != null)
+            {
+                Directory.CreateDirectory(Path.GetDirectoryName(_logFile)!);
+                int maxSizeMB = _logMaxSizeMB > 0 ? _logMaxSizeMB : DefaultFixedLogMaxSizeMB;
+                _logger = new AsyncLogger(_logFile, (long)maxSizeMB * 1024 * 1024, _logMaxFiles);
+            }
+            else
+            {
+                var logDir = Path.Combine(Environment.GetFolderPath(Environment.SpecialFolder.CommonApplicationData), "windows_service_logs");
+                Directory.CreateDirectory(logDir);
+                var logFile = Path.Combine(logDir, $"{_serviceName}_{DateTime.Now:yyyyMMdd_HHmmss}.log");
+                _logger = new AsyncLogger(logFile, (long)_logMaxSizeMB * 1024 * 1024, _logMaxFiles);
+            }
+
+            _logger.Log($"Service {LogName} starting");
+        }
+
+        private void LogDebug(string message)
+        {
+            if (_debugMode) _logger?.Log("DEBUG: " + message);
         }
 
         private void LogCriticalError(Exception ex)
@@ -65,15 +130,42 @@ namespace Services.Core.Services
             catch { }
         }
 
+        // Freezes the target process; health checks are skipped while paused so they don't trigger a restart
+        protected override void OnPause()
+        {
+            var process = _process;
+            if (process == null || process.HasExited) return;
+
+            ProcessUtils.SuspendProcess(process.Id);
+            _isPaused = true;
+            _logger?.Log("Process suspended");
+        }
+
+        protected override void OnContinue()
+        {
+            var process = _process;
+            _isPaused = false;
+            if (process == null || process.HasExited) return;
+
+            ProcessUtils.ResumeProcess(process.Id);
+            _healthFailures = 0;
+            _logger?.Log("Process resumed");
+        }
+
         protected override void OnStop()
         {
             _isStopping = true;
+            _requestTimer?.Dispose();
+            _requestTimer = null;
+            _healthTimer?.Dispose();
+            _healthTimer = null;
+
             if (_process != null && !_process.HasExited)
             {
                 try
                 {
                     _process.Kill(true);
-                    _process.WaitForExit(5000);
+                    _process.WaitForExit(_stopGracePeriodSeconds * 1000);
                 }
                 catch (Exception ex)
                 {
@@ -83,6 +175,11 @@ namespace Services.Core.Services
 
             _process?.Dispose();
             _process = null;
+            WriteParameter("TargetPid", 0);
+            RecordPidStop();
+
+            _job?.Dispose();
+            _job = null;
 
             _logger?.Dispose();
             _logger = null;
@@ -117,6 +214,322 @@ namespace Services.Core.Services
             return false;
         }
 
+        private void LoadLimits()
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters");
+                if (key == null) return;
+
+                _restartPolicy = ServiceUtils.ReadRestartPolicy(key);
+                if (key.GetValue("StopGracePeriodSeconds") is int grace) _stopGracePeriodSeconds = grace;
+                if (key.GetValue("LogMaxSizeMB") is int logMax) _logMaxSizeMB = logMax;
+                if (key.GetValue("LogMaxFiles") is int logFiles && logFiles > 0) _logMaxFiles = logFiles;
+                if (key.GetValue("LogFile") is string logFile && logFile.Length > 0) _logFile = logFile;
+                _healthCheck = ServiceUtils.ReadHealthCheck(key);
+                _debugMode = key.GetValue("DebugMode") is int debug && debug == 1;
+            }
+            catch { }
+        }
+
+        private void ApplyJobObject(Process process)
+        {
+            try
+            {
+                if (_job == null)
+                {
+                    using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters");
+                    if (key?.GetValue("MemoryLimitMB") is not long limitMB || limitMB <= 0) return;
+
+                    _job = new JobObject(JobObject.GetJobName(_serviceName));
+                    _job.SetLimits((ulong)limitMB * 1024 * 1024);
+                    _logger?.Log($"Job object created with memory limit {limitMB} MB");
+                }
+
+                _job.AssignProcess(process.Handle);
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to apply job object: {ex.Message}");
+            }
+        }
+
+        private void ApplyEnvironment(ProcessStartInfo psi)
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters");
+                if (key?.GetValue("Environment") is not string[] lines) return;
+
+                foreach (var (name, value) in ServiceUtils.ParseEnvironmentLines(lines))
+                {
+                    psi.Environment[name] = Environment.ExpandEnvironmentVariables(value);
+                }
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to apply environment: {ex.Message}");
+            }
+        }
+
+        private void ApplyAffinityMask(Process process)
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters");
+                if (key?.GetValue("AffinityMask") is long mask && mask != 0)
+                {
+                    process.ProcessorAffinity = (IntPtr)mask;
+                    _logger?.Log($"Affinity mask set to 0x{mask:X}");
+                }
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to set affinity mask: {ex.Message}");
+            }
+        }
+
+        private void ApplyPriority(Process process)
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters");
+                if (key?.GetValue("ProcessPriority") is string priority && Enum.TryParse<ProcessPriorityClass>(priority, true, out var priorityClass))
+                {
+                    process.PriorityClass = priorityClass;
+                    _logger?.Log($"Priority class set to {priorityClass}");
+                }
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to set priority class: {ex.Message}");
+            }
+        }
+
+        private void WriteParameter(string name, object value)
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters", true);
+                key?.SetValue(name, value);
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to write parameter {name}: {ex.Message}");
+            }
+        }
+
+        // Restart statistics are persisted so the manager can report them across wrapper restarts
+        private void RecordCrash()
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters", true);
+                if (key == null) return;
+
+                var now = DateTime.Now.ToString("o");
+                int total = key.GetValue("TotalCrashes") is int t ? t : 0;
+                key.SetValue("TotalCrashes", total + 1);
+                if (total == 0) key.SetValue("FirstCrashTime", now);
+                key.SetValue("LastCrashTime", now);
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to record crash: {ex.Message}");
+            }
+        }
+
+        // initiator is one of the Service.LastRestartInitiator values
+        private void RecordRestart(string reason, string initiator)
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters", true);
+                if (key == null) return;
+
+                int count = key.GetValue("RestartCount") is int c ? c : 0;
+                key.SetValue("RestartCount", count + 1);
+                key.SetValue("LastRestartTime", DateTime.Now.ToString("o"));
+                key.SetValue("LastRestartReason", reason, RegistryValueKind.String);
+                key.SetValue("LastRestartInitiator", initiator, RegistryValueKind.String);
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to record restart: {ex.Message}");
+            }
+        }
+
+        // The manager talks to the running wrapper through request values in the Parameters key
+        private void PollRequests()
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters", true);
+                if (key == null) return;
+
+                bool debugMode = key.GetValue("DebugMode") is int debug && debug == 1;
+                if (debugMode != _debugMode)
+                {
+                    _debugMode = debugMode;
+                    _logger?.Log(debugMode ? "Debug mode enabled" : "Debug mode disabled");
+                }
+
+                if (key.GetValue("DNSTestRequest") is string hostname && hostname.Length > 0)
+                {
+                    key.DeleteValue("DNSTestRequest", false);
+                    key.SetValue("DNSTestResult", JsonSerializer.Serialize(RunDnsTest(hostname)));
+                }
+
+                if (key.GetValue("RotateLogRequest") is string rotate && rotate == "1")
+                {
+                    key.DeleteValue("RotateLogRequest", false);
+                    _logger?.Log("Log rotation requested");
+                    _logger?.RotateNow();
+                    key.SetValue("LastRotationTime", DateTime.Now.ToString("o"), RegistryValueKind.String);
+                }
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to process requests: {ex.Message}");
+            }
+        }
+
+        // Kills the process after UnhealthyThreshold consecutive failures; the exit handler then starts it again
+        private void RunHealthCheck()
+        {
+            var config = _healthCheck;
+            var process = _process;
+            if (config == null || _isStopping || _isPaused || _healthRestartPending || process == null) return;
+            if (Interlocked.Exchange(ref _healthCheckRunning, 1) == 1) return;
+
+            try
+            {
+                if (process.HasExited) return;
+
+                var error = HealthCheckHelper.Check(config);
+                if (error == null)
+                {
+                    LogDebug($"Health check passed ({config.Type} {config.Target})");
+                    if (_healthFailures > 0) _logger?.Log("Health check recovered");
+                    _healthFailures = 0;
+                    return;
+                }
+
+                _healthFailures++;
+                _logger?.Log($"Health check failed ({_healthFailures}/{config.UnhealthyThreshold}): {error}");
+                if (_healthFailures < config.UnhealthyThreshold || _isStopping) return;
+
+                _logger?.Log("Process is unhealthy, restarting");
+                _healthFailures = 0;
+                _healthRestartReason = $"{config.UnhealthyThreshold} consecutive health check failures: {error}";
+                _healthRestartPending = true;
+                process.Kill(true);
+            }
+            catch (Exception ex)
+            {
+                _healthRestartPending = false;
+                _logger?.Log($"Health check error: {ex.Message}");
+            }
+            finally
+            {
+                Interlocked.Exchange(ref _healthCheckRunning, 0);
+            }
+        }
+
+        private static DNSTestResult RunDnsTest(string hostname)
+        {
+            var result = new DNSTestResult { Hostname = hostname };
+            var stopwatch = Stopwatch.StartNew();
+            try
+            {
+                result.Addresses = Dns.GetHostAddresses(hostname).Select(a => a.ToString()).ToList();
+            }
+            catch (Exception ex)
+            {
+                result.Error = ex.Message;
+            }
+            result.DurationMs = stopwatch.Elapsed.TotalMilliseconds;
+            result.Timestamp = DateTime.Now;
+            return result;
+        }
+
+        private void RecordPidStart(int pid)
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters", true);
+                if (key == null) return;
+
+                var history = ServiceUtils.ParsePidHistory(key.GetValue("PidHistory") as string[]);
+                history.Add(new PidHistoryEntry { Pid = pid, StartTime = DateTime.Now });
+                if (history.Count > ServiceUtils.MaxPidHistory)
+                    history.RemoveRange(0, history.Count - ServiceUtils.MaxPidHistory);
+
+                key.SetValue("PidHistory", ServiceUtils.FormatPidHistory(history), RegistryValueKind.MultiString);
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to record PID history: {ex.Message}");
+            }
+        }
+
+        private void RecordDailyStart()
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters", true);
+                if (key == null) return;
+
+                var (count, _) = ServiceUtils.ReadDailyStartCount(key);
+                key.SetValue("DailyStartCount", count + 1, RegistryValueKind.DWord);
+                key.SetValue("DailyStartCountDate", DateTime.Now.ToString(ServiceUtils.DailyStartDateFormat, CultureInfo.InvariantCulture), RegistryValueKind.String);
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to record daily start count: {ex.Message}");
+            }
+        }
+
+        private void RecordPidStop()
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters", true);
+                if (key == null) return;
+
+                var history = ServiceUtils.ParsePidHistory(key.GetValue("PidHistory") as string[]);
+                if (history.Count == 0 || history[^1].StopTime != null) return;
+
+                history[^1].StopTime = DateTime.Now;
+                key.SetValue("PidHistory", ServiceUtils.FormatPidHistory(history), RegistryValueKind.MultiString);
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to record PID history: {ex.Message}");
+            }
+        }
+
+        private void RecordExitCode(int exitCode)
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{_serviceName}\Parameters", true);
+                if (key == null) return;
+
+                var history = ServiceUtils.ParseExitCodeHistory(key.GetValue("ExitCodeHistory") as string);
+                // Exits caused by a stop request count as normal even though the process was killed
+                history.Add(new ExitCodeEntry { Timestamp = DateTime.Now, ExitCode = exitCode, ExitedNormally = exitCode == 0 || _isStopping });
+                if (history.Count > ServiceUtils.MaxExitCodeHistory)
+                    history.RemoveRange(0, history.Count - ServiceUtils.MaxExitCodeHistory);
+
+                key.SetValue("ExitCodeHistory", JsonSerializer.Serialize(history));
+            }
+            catch (Exception ex)
+            {
+                _logger?.Log($"Failed to record exit code: {ex.Message}");
+            }
+        }
+
         private void StartTargetProcess((string ExePath, string Args, string WorkingDir) config)
         {
             try
@@ -134,6 +547,16 @@ namespace Services.Core.Services
                     RedirectStandardError = true
                 };
 
+                ApplyEnvironment(psi);
+                if (_debugMode)
+                {
+                    LogDebug($"Command line: \"{psi.FileName}\" {psi.Arguments}");
+                    LogDebug($"Working directory: {psi.WorkingDirectory}");
+                    foreach (var (name, value) in psi.Environment.OrderBy(kv => kv.Key, StringComparer.OrdinalIgnoreCase))
+                    {
+                        LogDebug($"Environment: {name}={value}");
+                    }
+                }
                 _process = new Process { StartInfo = psi };
 
                 _process.OutputDataReceived += (s, e) => { if (e.Data != null) _logger?.Log(e.Data); };
@@ -147,14 +570,35 @@ namespace Services.Core.Services
                 _process.BeginOutputReadLine();
                 _process.BeginErrorReadLine();
 
+                ApplyJobObject(_process);
+                ApplyAffinityMask(_process);
+                ApplyPriority(_process);
+                WriteParameter("TargetPid", _process.Id);
+                RecordPidStart(_process.Id);
+                RecordDailyStart();
+                _processStartTime = DateTime.Now;
+
                 _process.EnableRaisingEvents = true;
                 _process.Exited += (s, e) =>
                 {
                     int exitCode = _process.ExitCode;
-                    _logger?.Log($"Process exited (code: {exitCode})");
+                    _logger?.Log($"{LogName}: process exited (code: {exitCode})");
+                    WriteParameter("TargetPid", 0);
+                    RecordPidStop();
+                    RecordExitCode(exitCode);
 
                     if (_isStopping) return;
 
+                    if (_healthRestartPending)
+                    {
+                        _healthRestartPending = false;
+                        RecordRestart(_healthRestartReason, "health-check");
+                        StartTargetProcess(config);
+                        return;
+                    }
+
+                    if (exitCode != 0) RecordCrash();
+
                     if (!_autoRestart || exitCode == 0)
                     {
                         _logger?.Log(exitCode == 0 ? "Normal exit, not restarting" : "AutoRestart disabled");
@@ -162,50 +606,55 @@ namespace Services.Core.Services
                         return;
                     }
 
-                    if ((DateTime.Now - _lastRestartTime).TotalMinutes > 10)
+                    if (DateTime.Now - _processStartTime >= _restartPolicy.ResetAfter)
                         _restartCount = 0;
 
-                    if (++_restartCount > MaxRestarts)
+                    if (!TryGetRestartDelay(out var delay))
                     {
-                        _logger?.Log($"Max restarts ({MaxRestarts}) exceeded. Stopping.");
                         Stop();
                         return;
                     }
 
-                    int delay = _restartDelayMs << Math.Min(_restartCount - 1, 4);
-                    _lastRestartTime = DateTime.Now;
-
-                    _logger?.Log($"Restart {_restartCount}/{MaxRestarts} in {delay}ms");
+                    _logger?.Log($"Restart {_restartCount}/{_restartPolicy.MaxAttempts} in {delay.TotalMilliseconds:0}ms");
                     Task.Delay(delay).ContinueWith(_ =>
                     {
-                        if (!_isStopping) StartTargetProcess(config);
+                        if (_isStopping) return;
+                        RecordRestart($"Process exited with code {exitCode}", "crash");
+                        StartTargetProcess(config);
                     });
                 };
             }
             catch (Exception ex)
             {
                 _logger?.Log($"Failed to start: {ex.Message}");
+                LogDebug(ex.ToString());
 
                 if (!_autoRestart) throw;
 
-                if ((DateTime.Now - _lastRestartTime).TotalMinutes > 10)
-                    _restartCount = 0;
-
-                if (++_restartCount > MaxRestarts)
-                {
-                    _logger?.Log($"Max restarts ({MaxRestarts}) exceeded. Stopping.");
-                    throw;
-                }
-
-                int delay = _restartDelayMs << Math.Min(_restartCount - 1, 4);
-                _lastRestartTime = DateTime.Now;
+                if (!TryGetRestartDelay(out var delay)) throw;
 
-                _logger?.Log($"Retry {_restartCount}/{MaxRestarts} in {delay}ms");
+                _logger?.Log($"Retry {_restartCount}/{_restartPolicy.MaxAttempts} in {delay.TotalMilliseconds:0}ms");
                 Task.Delay(delay).ContinueWith(_ =>
                 {
-                    if (!_isStopping) StartTargetProcess(config);
+                    if (_isStopping) return;
+                    RecordRestart($"Start failed: {ex.Message}", "wrapper-policy");
+                    StartTargetProcess(config);
                 });
             }
         }
+
+        // Exponential backoff per the restart policy; false once the attempts are exhausted
+        private bool TryGetRestartDelay(out TimeSpan delay)
+        {
+            delay = TimeSpan.Zero;
+            if (++_restartCount > _restartPolicy.MaxAttempts)
+            {
+                _logger?.Log($"{LogName}: max restarts ({_restartPolicy.MaxAttempts}) exceeded. Stopping.");
+                return false;
+            }
+
+            delay = _restartPolicy.GetDelay(_restartCount);
+            return true;
+        }
     }
 }
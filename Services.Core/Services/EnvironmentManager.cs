@@ -1,6 +1,14 @@
 using System;
+using System.Collections;
+using System.Collections.Generic;
+using System.IO;
+using System.Linq;
 using System.Runtime.InteropServices;
+using System.Security.Principal;
+using System.Text.Json;
+using System.Text.RegularExpressions;
 using Microsoft.Win32;
+using Services.Core.Models;
 
 namespace Services.Core.Services
 {
@@ -9,6 +17,12 @@ namespace Services.Core.Services
         private const int HWND_BROADCAST = 0xffff;
         private const int WM_SETTINGCHANGE = 0x001A;
         private const int SMTO_ABORTIFHUNG = 0x0002;
+        private const string SystemEnvironmentKey = @"SYSTEM\CurrentControlSet\Control\Session Manager\Environment";
+        private const string UserEnvironmentKey = "Environment";
+
+        private const string PresetsKey = @"SOFTWARE\WindowsServiceManager\EnvPresets";
+
+        private static readonly Regex VariableNameRegex = new(@"^[A-Za-z_][A-Za-z0-9_]*$", RegexOptions.Compiled);
 
         [DllImport("user32.dll", SetLastError = true, CharSet = CharSet.Auto)]
         private static extern IntPtr SendMessageTimeout(
@@ -22,8 +36,7 @@ namespace Services.Core.Services
 
         public void AddToPath(string path)
         {
-            const string keyName = @"SYSTEM\CurrentControlSet\Control\Session Manager\Environment";
-            using (var key = Registry.LocalMachine.OpenSubKey(keyName, true))
+            using (var key = Registry.LocalMachine.OpenSubKey(SystemEnvironmentKey, true))
             {
                 if (key == null) throw new Exception("Cannot open Environment registry key");
 
@@ -41,11 +54,501 @@ namespace Services.Core.Services
 
                 var newPath = currentPath.TrimEnd(';') + ";" + path;
                 key.SetValue("Path", newPath, RegistryValueKind.ExpandString);
+                RecordChange("modify", "Path", currentPath, newPath);
 
                 BroadcastEnvironmentChange();
             }
         }
 
+        public NameCollisionWarning? AddSystemEnvironmentVariable(string varName, string value)
+        {
+            if (string.IsNullOrWhiteSpace(varName) || varName.Contains('=') || varName.Contains('\0'))
+                throw new ArgumentException("Invalid environment variable name.");
+
+            using (var key = Registry.LocalMachine.OpenSubKey(SystemEnvironmentKey, true))
+            {
+                if (key == null) throw new Exception("Cannot open Environment registry key");
+                var oldValue = key.GetValue(varName, null, RegistryValueOptions.DoNotExpandEnvironmentNames) as string;
+                key.SetValue(varName, value, value.Contains('%') ? RegistryValueKind.ExpandString : RegistryValueKind.String);
+                RecordChange(oldValue == null ? "add" : "modify", varName, oldValue, value);
+            }
+            BroadcastEnvironmentChange();
+
+            var (hasConflict, userValue) = HasEnvironmentVariableNameConflict(varName);
+            if (!hasConflict) return null;
+
+            return new NameCollisionWarning
+            {
+                VarName = varName,
+                UserValue = userValue,
+                Message = $"User variable {varName} shadows the system value in interactive sessions."
+            };
+        }
+
+        public void DeleteSystemEnvironmentVariable(string varName)
+        {
+            using (var key = Registry.LocalMachine.OpenSubKey(SystemEnvironmentKey, true))
+            {
+                if (key == null) throw new Exception("Cannot open Environment registry key");
+                if (key.GetValue(varName, null, RegistryValueOptions.DoNotExpandEnvironmentNames) is not string oldValue)
+                    throw new KeyNotFoundException($"System environment variable {varName} does not exist");
+
+                key.DeleteValue(varName);
+                RecordChange("delete", varName, oldValue, null);
+            }
+            BroadcastEnvironmentChange();
+        }
+
+        // Removes every PATH entry equal to pathSegment, ignoring case, surrounding quotes and trailing separators
+        public void DeletePathEntry(string pathSegment)
+        {
+            var target = NormalizePathEntry(pathSegment);
+            if (target.Length == 0) throw new ArgumentException("Path entry cannot be empty.");
+
+            using (var key = Registry.LocalMachine.OpenSubKey(SystemEnvironmentKey, true))
+            {
+                if (key == null) throw new Exception("Cannot open Environment registry key");
+
+                var currentPath = key.GetValue("Path", "", RegistryValueOptions.DoNotExpandEnvironmentNames) as string ?? "";
+                var paths = currentPath.Split(';', StringSplitOptions.RemoveEmptyEntries);
+                var kept = paths.Where(p => !string.Equals(NormalizePathEntry(p), target, StringComparison.OrdinalIgnoreCase)).ToList();
+                if (kept.Count == paths.Length)
+                    throw new KeyNotFoundException($"Path entry {pathSegment} does not exist");
+
+                var newPath = string.Join(";", kept);
+                key.SetValue("Path", newPath, RegistryValueKind.ExpandString);
+                RecordChange("modify", "Path", currentPath, newPath);
+            }
+            BroadcastEnvironmentChange();
+        }
+
+        private static string NormalizePathEntry(string entry)
+        {
+            return entry.Trim().Trim('"').TrimEnd('\\', '/');
+        }
+
+        // PATH is merged rather than shadowed, so it never conflicts
+        public (bool HasConflict, string UserValue) HasEnvironmentVariableNameConflict(string varName)
+        {
+            if (string.Equals(varName, "Path", StringComparison.OrdinalIgnoreCase)) return (false, "");
+
+            using var key = OpenEnvironmentKey("user", false);
+            return key.GetValue(varName, null, RegistryValueOptions.DoNotExpandEnvironmentNames) is string userValue
+                ? (true, userValue)
+                : (false, "");
+        }
+
+        public EnvImportResult ImportFromDotEnvFile(string filePath, string scope, bool overwrite)
+        {
+            if (!File.Exists(filePath)) throw new FileNotFoundException(".env file not found", filePath);
+
+            var variables = ParseDotEnv(File.ReadAllLines(filePath));
+            var result = new EnvImportResult();
+
+            using (var key = OpenEnvironmentKey(scope, true))
+            {
+                foreach (var (name, value) in variables)
+                {
+                    var existing = key.GetValue(name, null, RegistryValueOptions.DoNotExpandEnvironmentNames);
+                    if (existing != null && !overwrite)
+                    {
+                        result.Skipped++;
+                        continue;
+                    }
+
+                    key.SetValue(name, value, value.Contains('%') ? RegistryValueKind.ExpandString : RegistryValueKind.String);
+                    RecordChange(existing == null ? "add" : "modify", name, existing as string, value);
+                    if (existing == null) result.Added++;
+                    else result.Updated++;
+                }
+            }
+
+            if (result.Added + result.Updated > 0) BroadcastEnvironmentChange();
+            return result;
+        }
+
+        // Parses KEY=VALUE lines, supporting comments, quoted values and the "export" prefix.
+        // Lines with invalid variable names are ignored.
+        public static List<KeyValuePair<string, string>> ParseDotEnv(IEnumerable<string> lines)
+        {
+            var variables = new List<KeyValuePair<string, string>>();
+
+            foreach (var rawLine in lines)
+            {
+                var line = rawLine.Trim();
+                if (line.Length == 0 || line.StartsWith('#')) continue;
+
+                if (line.StartsWith("export ", StringComparison.Ordinal))
+                    line = line.Substring("export ".Length).TrimStart();
+
+                int eq = line.IndexOf('=');
+                if (eq <= 0) continue;
+
+                var name = line.Substring(0, eq).Trim();
+                var value = line.Substring(eq + 1).Trim();
+                if (!VariableNameRegex.IsMatch(name)) continue;
+
+                if (value.Length >= 2 && (value[0] == '"' || value[0] == '\'') && value[^1] == value[0])
+                {
+                    value = value.Substring(1, value.Length - 2);
+                }
+                else
+                {
+                    // Unquoted values may carry a trailing comment
+                    int comment = value.IndexOf(" #", StringComparison.Ordinal);
+                    if (comment >= 0) value = value.Substring(0, comment).TrimEnd();
+                }
+
+                variables.Add(new KeyValuePair<string, string>(name, value));
+            }
+
+            return variables;
+        }
+
+        private const int MaxVariableValueLength = 32767;
+
+        public List<ValidationWarning> ValidateVariableValue(string varName, string value, string scope)
+        {
+            var warnings = new List<ValidationWarning>();
+            bool isSystemScope = scope.ToLowerInvariant() switch
+            {
+                "system" or "machine" => true,
+                "user" => false,
+                _ => throw new ArgumentException($"Unknown environment scope: {scope}")
+            };
+
+            if (value.Contains('\0'))
+                warnings.Add(new ValidationWarning { Code = "null-byte", Message = "Value contains a null character." });
+            if (value.Length > MaxVariableValueLength)
+                warnings.Add(new ValidationWarning { Code = "too-long", Message = $"Value exceeds {MaxVariableValueLength} characters." });
+
+            if (isSystemScope && value.Contains("%USERPROFILE%", StringComparison.OrdinalIgnoreCase))
+                warnings.Add(new ValidationWarning { Code = "user-path-in-system", Message = "System variables should not reference %USERPROFILE%; services run under a different profile." });
+
+            if (!IsPathVariable(varName)) return warnings;
+
+            var entry = value.Trim().Trim('"');
+            if (entry.Contains(';'))
+                warnings.Add(new ValidationWarning { Code = "contains-separator", Message = "Value contains ';'. Add each directory as a separate entry." });
+            if (entry.EndsWith(".exe", StringComparison.OrdinalIgnoreCase))
+                warnings.Add(new ValidationWarning { Code = "points-to-file", Message = "PATH entries must be directories, not executables." });
+            if (entry.Length > 3 && entry.EndsWith('\\'))
+                warnings.Add(new ValidationWarning { Code = "trailing-backslash", Message = "Trailing backslash is redundant." });
+            if (entry.Any(c => c > 127))
+                warnings.Add(new ValidationWarning { Code = "non-ascii", Message = "Path contains non-ASCII characters, which some programs cannot decode." });
+
+            if (!entry.Contains(';') && entry.Length > 0 && !Directory.Exists(Environment.ExpandEnvironmentVariables(entry)))
+                warnings.Add(new ValidationWarning { Code = "not-found", Message = $"Directory does not exist: {entry}" });
+
+            return warnings;
+        }
+
+        private static bool IsPathVariable(string varName)
+        {
+            return varName.EndsWith("PATH", StringComparison.OrdinalIgnoreCase);
+        }
+
+        public string GetUserEnvironmentVariableWithDefault(string varName, string defaultValue)
+        {
+            return ReadVariableWithDefault("user", varName, defaultValue);
+        }
+
+        public string GetSystemEnvironmentVariableWithDefault(string varName, string defaultValue)
+        {
+            return ReadVariableWithDefault("system", varName, defaultValue);
+        }
+
+        private const int MaxExpansionDepth = 10;
+        private static readonly Regex VariableReferenceRegex = new(@"%([^%]+)%", RegexOptions.Compiled);
+
+        // One step per variable involved, in the order they are referenced. Variables not found in the
+        // registry scope (e.g. ProgramFiles) come from the process environment; circular references stay unexpanded.
+        public List<ExpansionStep> GetExpansionChain(string varName, string scope)
+        {
+            using var key = OpenEnvironmentKey(scope, false);
+            var steps = new List<ExpansionStep>();
+            var seen = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
+
+            string? Expand(string name, int depth, HashSet<string> active)
+            {
+                var raw = key.GetValue(name, null, RegistryValueOptions.DoNotExpandEnvironmentNames) as string
+                          ?? Environment.GetEnvironmentVariable(name);
+                if (raw == null) return null;
+
+                var step = new ExpansionStep { VariableName = name, RawValue = raw };
+                if (seen.Add(name)) steps.Add(step);
+
+                active.Add(name);
+                step.ExpandedValue = VariableReferenceRegex.Replace(raw, m =>
+                {
+                    var reference = m.Groups[1].Value;
+                    if (depth >= MaxExpansionDepth || active.Contains(reference)) return m.Value;
+                    return Expand(reference, depth + 1, active) ?? m.Value;
+                });
+                active.Remove(name);
+
+                return step.ExpandedValue;
+            }
+
+            if (Expand(varName, 0, new HashSet<string>(StringComparer.OrdinalIgnoreCase)) == null)
+                throw new KeyNotFoundException($"Environment variable {varName} not found");
+            return steps;
+        }
+
+        private static string ReadVariableWithDefault(string scope, string varName, string defaultValue)
+        {
+            try
+            {
+                using var key = OpenEnvironmentKey(scope, false);
+                return key.GetValue(varName) is string value && value.Length > 0 ? value : defaultValue;
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to read {scope} variable {varName}: {ex.Message}");
+                return defaultValue;
+            }
+        }
+
+        public Dictionary<string, string> ListSystemEnvironmentVariables()
+        {
+            using var key = OpenEnvironmentKey("system", false);
+            return ReadAllValues(key);
+        }
+
+        public Dictionary<string, string> ListUserEnvironmentVariables()
+        {
+            using var key = OpenEnvironmentKey("user", false);
+            return ReadAllValues(key);
+        }
+
+        public Dictionary<string, string> GetCurrentSessionEnvironment()
+        {
+            var result = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+            foreach (DictionaryEntry entry in Environment.GetEnvironmentVariables())
+            {
+                result[(string)entry.Key] = entry.Value as string ?? "";
+            }
+            return result;
+        }
+
+        public EnvironmentDiff GetEnvironmentDiff()
+        {
+            var session = GetCurrentSessionEnvironment();
+            var system = ListSystemEnvironmentVariables();
+            var user = ListUserEnvironmentVariables();
+
+            // Same precedence Windows uses when building a new logon environment: user overrides system, PATH is concatenated
+            var registry = new Dictionary<string, string>(system, StringComparer.OrdinalIgnoreCase);
+            foreach (var (name, value) in user)
+            {
+                if (string.Equals(name, "Path", StringComparison.OrdinalIgnoreCase) && registry.TryGetValue(name, out var systemPath))
+                    registry[name] = systemPath.TrimEnd(';') + ";" + value;
+                else
+                    registry[name] = value;
+            }
+
+            var diff = new EnvironmentDiff();
+            foreach (var (name, rawValue) in registry)
+            {
+                var value = Environment.ExpandEnvironmentVariables(rawValue);
+                if (!session.TryGetValue(name, out var sessionValue))
+                    diff.Removed[name] = value;
+                else if (!string.Equals(value, sessionValue, StringComparison.Ordinal))
+                    diff.Changed[name] = new EnvironmentValueChange { RegistryValue = value, SessionValue = sessionValue };
+            }
+
+            foreach (var (name, value) in session)
+            {
+                if (!registry.ContainsKey(name)) diff.Added[name] = value;
+            }
+
+            return diff;
+        }
+
+        private static Dictionary<string, string> ReadAllValues(RegistryKey key)
+        {
+            var result = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+            foreach (var name in key.GetValueNames())
+            {
+                if (key.GetValue(name, null, RegistryValueOptions.DoNotExpandEnvironmentNames) is string value)
+                    result[name] = value;
+            }
+            return result;
+        }
+
+        private static readonly string HistoryFile = Path.Combine(
+            Environment.GetFolderPath(Environment.SpecialFolder.ApplicationData), "WindowsServiceManager", "env_history.jsonl");
+        private static readonly object HistoryLock = new();
+
+        // Most recent changes last; an empty name returns changes of all variables
+        public List<EnvironmentChangeEntry> GetEnvironmentVariableHistory(string varName, int n)
+        {
+            if (!File.Exists(HistoryFile)) return new List<EnvironmentChangeEntry>();
+
+            string[] lines;
+            lock (HistoryLock)
+            {
+                lines = File.ReadAllLines(HistoryFile);
+            }
+
+            var entries = new List<EnvironmentChangeEntry>();
+            foreach (var line in lines)
+            {
+                if (string.IsNullOrWhiteSpace(line)) continue;
+                try
+                {
+                    var entry = JsonSerializer.Deserialize<EnvironmentChangeEntry>(line);
+                    if (entry != null && (string.IsNullOrEmpty(varName) || string.Equals(entry.VarName, varName, StringComparison.OrdinalIgnoreCase)))
+                        entries.Add(entry);
+                }
+                catch (JsonException) { }
+            }
+            return entries.Skip(Math.Max(0, entries.Count - n)).ToList();
+        }
+
+        private static void RecordChange(string operation, string varName, string? oldValue, string? newValue)
+        {
+            try
+            {
+                var entry = new EnvironmentChangeEntry
+                {
+                    Timestamp = DateTime.Now,
+                    Operation = operation,
+                    VarName = varName,
+                    OldValue = oldValue ?? "",
+                    NewValue = newValue ?? "",
+                    ChangedBy = WindowsIdentity.GetCurrent().Name
+                };
+
+                lock (HistoryLock)
+                {
+                    Directory.CreateDirectory(Path.GetDirectoryName(HistoryFile)!);
+                    File.AppendAllText(HistoryFile, JsonSerializer.Serialize(entry) + Environment.NewLine);
+                }
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to record environment change: {ex.Message}");
+            }
+        }
+
+        // Replaces any preset of the same name
+        public void SavePreset(EnvironmentPreset preset, string scope)
+        {
+            if (string.IsNullOrWhiteSpace(preset.Name) || preset.Name.Contains('\\'))
+                throw new ArgumentException("Invalid preset name.");
+            using (OpenEnvironmentKey(scope, false)) { }
+            foreach (var name in preset.Variables.Keys)
+            {
+                if (!VariableNameRegex.IsMatch(name)) throw new ArgumentException($"Invalid environment variable name: {name}");
+            }
+
+            using var presetsKey = Registry.CurrentUser.CreateSubKey(PresetsKey, true);
+            presetsKey.DeleteSubKeyTree(preset.Name, false);
+            using var key = presetsKey.CreateSubKey(preset.Name, true);
+            key.SetValue("Description", preset.Description, RegistryValueKind.String);
+            key.SetValue("Scope", scope.ToLowerInvariant(), RegistryValueKind.String);
+            key.SetValue("PathEntries", preset.PathEntries.ToArray(), RegistryValueKind.MultiString);
+
+            using var variablesKey = key.CreateSubKey("Variables", true);
+            foreach (var (name, value) in preset.Variables)
+            {
+                variablesKey.SetValue(name, value, RegistryValueKind.String);
+            }
+        }
+
+        public EnvironmentPreset GetPreset(string name)
+        {
+            using var key = Registry.CurrentUser.OpenSubKey($@"{PresetsKey}\{name}");
+            if (key == null) throw new KeyNotFoundException($"Environment preset {name} not found");
+
+            var preset = new EnvironmentPreset
+            {
+                Name = name,
+                Description = key.GetValue("Description") as string ?? "",
+                Scope = key.GetValue("Scope") as string ?? "user",
+                PathEntries = (key.GetValue("PathEntries") as string[] ?? Array.Empty<string>()).ToList()
+            };
+            using var variablesKey = key.OpenSubKey("Variables");
+            if (variablesKey != null)
+            {
+                foreach (var (varName, value) in ReadAllValues(variablesKey))
+                {
+                    preset.Variables[varName] = value;
+                }
+            }
+            return preset;
+        }
+
+        public List<string> ListPresets()
+        {
+            using var presetsKey = Registry.CurrentUser.OpenSubKey(PresetsKey);
+            return presetsKey?.GetSubKeyNames().OrderBy(n => n, StringComparer.OrdinalIgnoreCase).ToList() ?? new List<string>();
+        }
+
+        // Path entries already present are counted as skipped, like existing variables without overwrite
+        public EnvImportResult LoadPreset(string name, string targetScope, bool overwrite)
+        {
+            var preset = GetPreset(name);
+            var result = new EnvImportResult();
+
+            using (var key = OpenEnvironmentKey(targetScope, true))
+            {
+                foreach (var (varName, value) in preset.Variables)
+                {
+                    var existing = key.GetValue(varName, null, RegistryValueOptions.DoNotExpandEnvironmentNames);
+                    if (existing != null && !overwrite)
+                    {
+                        result.Skipped++;
+                        continue;
+                    }
+
+                    key.SetValue(varName, value, value.Contains('%') ? RegistryValueKind.ExpandString : RegistryValueKind.String);
+                    RecordChange(existing == null ? "add" : "modify", varName, existing as string, value);
+                    if (existing == null) result.Added++;
+                    else result.Updated++;
+                }
+
+                if (preset.PathEntries.Count > 0)
+                {
+                    var currentPath = key.GetValue("Path", "", RegistryValueOptions.DoNotExpandEnvironmentNames) as string ?? "";
+                    var entries = currentPath.Split(';', StringSplitOptions.RemoveEmptyEntries).Select(p => p.Trim()).ToList();
+                    foreach (var entry in preset.PathEntries)
+                    {
+                        if (entries.Contains(entry.Trim(), StringComparer.OrdinalIgnoreCase))
+                        {
+                            result.Skipped++;
+                            continue;
+                        }
+                        entries.Add(entry.Trim());
+                        result.Added++;
+                    }
+
+                    var newPath = string.Join(";", entries);
+                    if (!string.Equals(newPath, currentPath.TrimEnd(';'), StringComparison.Ordinal))
+                    {
+                        key.SetValue("Path", newPath, RegistryValueKind.ExpandString);
+                        RecordChange("modify", "Path", currentPath, newPath);
+                    }
+                }
+            }
+
+            if (result.Added + result.Updated > 0) BroadcastEnvironmentChange();
+            return result;
+        }
+
+        private static RegistryKey OpenEnvironmentKey(string scope, bool writable)
+        {
+            RegistryKey? key = scope.ToLowerInvariant() switch
+            {
+                "system" or "machine" => Registry.LocalMachine.OpenSubKey(SystemEnvironmentKey, writable),
+                "user" => Registry.CurrentUser.OpenSubKey(UserEnvironmentKey, writable),
+                _ => throw new ArgumentException($"Unknown environment scope: {scope}")
+            };
+            if (key == null) throw new Exception("Cannot open Environment registry key");
+            return key;
+        }
+
         private void BroadcastEnvironmentChange()
         {
             try
@@ -1,8 +1,11 @@
 using System;
+using System.Collections.Generic;
 using System.IO;
+using System.Globalization;
 using System.Linq;
 using System.Threading.Tasks;
 using Microsoft.Win32;
+using Services.Core.Models;
 
 namespace Services.Core.Services
 {
@@ -10,6 +13,7 @@ namespace Services.Core.Services
     {
         private static readonly string LogDirectory = Path.Combine(Environment.GetFolderPath(Environment.SpecialFolder.CommonApplicationData), "windows_service_logs");
         private const int DefaultRetentionDays = 7;
+        public event EventHandler<ServiceWarningEventArgs>? LogTailError;
 
         public LogManager()
         {
@@ -26,6 +30,9 @@ namespace Services.Core.Services
 
         public string? GetLatestLogPath(string serviceName)
         {
+            var configured = GetConfiguredLogFile(serviceName);
+            if (configured != null) return File.Exists(configured) ? configured : null;
+
             if (!Directory.Exists(LogDirectory)) return null;
 
             string? latestFile = null;
@@ -48,13 +55,27 @@ namespace Services.Core.Services
             return latestFile;
         }
 
+        // Set by WindowsServiceManager.SetServiceLogFile; such logs live outside LogDirectory
+        private static string? GetConfiguredLogFile(string serviceName)
+        {
+            try
+            {
+                using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceName}\Parameters");
+                return key?.GetValue("LogFile") is string logFile && logFile.Length > 0 ? logFile : null;
+            }
+            catch
+            {
+                return null;
+            }
+        }
+
         public async Task<string> ReadLogAsync(string? logPath)
         {
             if (string.IsNullOrEmpty(logPath) || !File.Exists(logPath)) return "未找到日志文件。";
 
             try
             {
-                using (var stream = new FileStream(logPath, FileMode.Open, FileAccess.Read, FileShare.ReadWrite))
+                using (var stream = new FileStream(logPath, FileMode.Open, FileAccess.Read, FileShare.ReadWrite | FileShare.Delete))
                 {
                     if (stream.Length > 100 * 1024)
                     {
@@ -78,6 +99,116 @@ namespace Services.Core.Services
             }
         }
 
+        public async Task<List<string>> TailServiceLogAsync(string serviceName, int lines)
+        {
+            var logPath = GetLatestLogPath(serviceName);
+            var tail = new Queue<string>();
+            if (logPath == null || lines <= 0) return tail.ToList();
+
+            using var stream = new FileStream(logPath, FileMode.Open, FileAccess.Read, FileShare.ReadWrite | FileShare.Delete);
+            using var reader = new StreamReader(stream);
+            string? line;
+            while ((line = await reader.ReadLineAsync()) != null)
+            {
+                tail.Enqueue(line);
+                if (tail.Count > lines) tail.Dequeue();
+            }
+            return tail.ToList();
+        }
+
+        // Text appended to the service log during the window. The log may roll over meanwhile, in which
+        // case everything in the new file counts.
+        public async Task<string> CaptureServiceLogAsync(string serviceName, TimeSpan duration)
+        {
+            var logPath = GetLatestLogPath(serviceName) ?? throw new FileNotFoundException($"No log file found for {serviceName}");
+            long start = new FileInfo(logPath).Length;
+
+            await Task.Delay(duration);
+
+            var currentPath = GetLatestLogPath(serviceName);
+            if (currentPath == null) return string.Empty;
+            if (!string.Equals(currentPath, logPath, StringComparison.OrdinalIgnoreCase)) start = 0;
+
+            using var stream = new FileStream(currentPath, FileMode.Open, FileAccess.Read, FileShare.ReadWrite | FileShare.Delete);
+            if (stream.Length < start) start = 0;
+            stream.Seek(start, SeekOrigin.Begin);
+            using var reader = new StreamReader(stream);
+            return await reader.ReadToEndAsync();
+        }
+
+        // Wrapper lines carry only "[HH:mm:ss]", so dates come from the file name (<id>_yyyyMMdd_HHmmss.log)
+        // and advance whenever the time of day goes backwards
+        public async Task<LogStats> GetServiceLogStatsAsync(string serviceName)
+        {
+            var logPath = GetLatestLogPath(serviceName);
+            if (logPath == null) throw new FileNotFoundException($"No log file found for {serviceName}");
+
+            var info = new FileInfo(logPath);
+            var stats = new LogStats { LogPath = logPath, FileSizeBytes = info.Length, LastModified = info.LastWriteTime };
+
+            var nameParts = Path.GetFileNameWithoutExtension(logPath).Split('_');
+            var day = nameParts.Length >= 3 && DateTime.TryParseExact(nameParts[^2], "yyyyMMdd", CultureInfo.InvariantCulture, DateTimeStyles.None, out var started)
+                ? started
+                : info.CreationTime.Date;
+            var since = DateTime.Now.AddHours(-24);
+            TimeSpan previousTime = TimeSpan.Zero;
+            int recentErrors = 0;
+
+            using var stream = new FileStream(logPath, FileMode.Open, FileAccess.Read, FileShare.ReadWrite | FileShare.Delete);
+            using var reader = new StreamReader(stream);
+            string? line;
+            while ((line = await reader.ReadLineAsync()) != null)
+            {
+                stats.TotalLines++;
+
+                DateTime? timestamp = null;
+                if (line.Length >= 10 && line[0] == '[' && TimeSpan.TryParseExact(line.AsSpan(1, 8), @"hh\:mm\:ss", CultureInfo.InvariantCulture, out var time))
+                {
+                    if (time < previousTime) day = day.AddDays(1);
+                    previousTime = time;
+                    timestamp = day + time;
+                }
+
+                if (line.Contains("error", StringComparison.OrdinalIgnoreCase))
+                {
+                    stats.ErrorLines++;
+                    if (timestamp >= since) recentErrors++;
+                }
+                else if (line.Contains("warn", StringComparison.OrdinalIgnoreCase))
+                {
+                    stats.WarningLines++;
+                }
+            }
+
+            stats.ErrorRate = recentErrors / 24.0;
+            return stats;
+        }
+
+        // Services without a log, or whose log cannot be read, map to an empty list
+        public async Task<Dictionary<string, List<string>>> GetAllServiceLogsAsync(IEnumerable<string> serviceIds, int lines)
+        {
+            var tasks = serviceIds.Select(async id =>
+            {
+                try
+                {
+                    return (Id: id, Lines: await Task.Run(() => TailServiceLogAsync(id, lines)));
+                }
+                catch (Exception ex)
+                {
+                    LogTailError?.Invoke(this, new ServiceWarningEventArgs
+                    {
+                        ServiceId = id,
+                        Kind = "log-tail-error",
+                        Message = ex.Message
+                    });
+                    return (Id: id, Lines: new List<string>());
+                }
+            });
+
+            var results = await Task.WhenAll(tasks);
+            return results.ToDictionary(r => r.Id, r => r.Lines);
+        }
+
         public void CleanupOldLogs(int retentionDays = DefaultRetentionDays)
         {
             if (!Directory.Exists(LogDirectory)) return;
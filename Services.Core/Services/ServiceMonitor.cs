@@ -104,6 +104,9 @@ namespace Services.Core.Services
                                 2 => "启动中",
                                 3 => "停止中",
                                 4 => "运行中",
+                                5 => "继续中",
+                                6 => "暂停中",
+                                7 => "已暂停",
                                 _ => "未知"
                             };
                             return (statusStr, (int)status.dwProcessId);
@@ -1,11 +1,19 @@
 using System;
+using System.Collections.Concurrent;
 using System.Collections.Generic;
+using System.ComponentModel;
 using System.Diagnostics;
 using System.IO;
 using System.Linq;
 using System.Runtime.InteropServices;
+using System.Security.AccessControl;
+using System.Security.Principal;
 using System.ServiceProcess;
+using System.Text.Json;
+using System.Text.RegularExpressions;
+using System.Threading;
 using System.Threading.Tasks;
+using System.Xml.Linq;
 using Microsoft.Win32;
 using Services.Core.Helpers;
 using Services.Core.Models;
@@ -17,10 +25,50 @@ namespace Services.Core.Services
         private Dictionary<string, Service> _services = new();
         private readonly Dictionary<string, ServiceMonitor> _monitors = new();
         public event EventHandler<Service>? ServiceUpdated;
+        public event EventHandler<ServiceWarningEventArgs>? ServiceWarning;
+        public event EventHandler? ServicesReloaded;
+        public event EventHandler<List<Service>>? ServicesUpdated;
         private readonly object _lock = new();
+        private Timer? _metricsTimer;
+        private static readonly TimeSpan MetricsInterval = TimeSpan.FromSeconds(30);
+        private Timer? _scheduleTimer;
+        private Timer? _bandwidthTimer;
+        private static readonly TimeSpan BandwidthInterval = TimeSpan.FromSeconds(5);
+        private const int MaxBandwidthSamples = 120;
+        private readonly Dictionary<string, List<NetworkBandwidthSample>> _bandwidthHistory = new();
+        private readonly Dictionary<string, (int Pid, ulong Received, ulong Sent, DateTime Time)> _bandwidthTotals = new();
+        // One sample per metrics interval: 60 minutes of history
+        private const int MaxPerformanceSamples = 120;
+        private readonly Dictionary<string, List<long>> _startLatencies = new();
+        private const int MaxStartLatencySamples = 20;
+        private readonly Dictionary<string, PerformanceHistory> _performanceHistory = new();
+        private readonly Dictionary<string, (int Pid, TimeSpan CpuTime, DateTime Time)> _cpuTimes = new();
+        private DateTime _lastScheduleMinute = DateTime.MinValue;
 
-        public WindowsServiceManager()
+        public bool ValidateWorkingDirOnStart { get; private set; } = true;
+
+        // Metrics sampling and scheduled start/stop only run in the one instance that calls this,
+        // so short-lived instances such as the --report CLI never act on schedules
+        public void StartBackgroundTasks()
         {
+            if (_scheduleTimer != null) return;
+
+            _metricsTimer = new Timer(_ => SampleMetrics(), null, MetricsInterval, MetricsInterval);
+            _bandwidthTimer = new Timer(_ => SampleBandwidth(), null, BandwidthInterval, BandwidthInterval);
+
+            var now = DateTime.Now;
+            var nextMinute = now.AddTicks(-(now.Ticks % TimeSpan.TicksPerMinute)).AddMinutes(1);
+            _scheduleTimer = new Timer(_ =>
+            {
+                try
+                {
+                    RunSchedulesAsync().GetAwaiter().GetResult();
+                }
+                catch (Exception ex)
+                {
+                    System.Diagnostics.Debug.WriteLine($"Schedule run failed: {ex.Message}");
+                }
+            }, null, nextMinute - now, TimeSpan.FromMinutes(1));
         }
 
         public async Task InitializeAsync()
@@ -29,6 +77,22 @@ namespace Services.Core.Services
             CleanupOrphanedMonitors();
         }
 
+        // Hard refresh: reloads from the registry and prunes index entries of deleted services. The list is
+        // swapped in one step by LoadServicesAsync, so concurrent callers never see it empty.
+        public async Task<List<Service>> ReloadServiceListAsync()
+        {
+            _trustCache.Clear();
+            _permissionsCache.Clear();
+
+            await LoadServicesAsync();
+            ReconcileManagedServicesIndex();
+            CleanupOrphanedMonitors();
+            await RefreshServiceStatusesAsync();
+
+            ServicesReloaded?.Invoke(this, EventArgs.Empty);
+            return await GetServicesSnapshotAsync();
+        }
+
         public async Task<List<Service>> GetServicesAsync()
         {
             return await GetServicesSnapshotAsync();
@@ -44,8 +108,53 @@ namespace Services.Core.Services
 
             if (servicesToUpdate.Count == 0) return;
 
-            var tasks = servicesToUpdate.Select(UpdateServiceStatusAsync);
-            await Task.WhenAll(tasks);
+            await Task.Run(() =>
+            {
+                using var session = OpenSCMSession();
+                foreach (var service in servicesToUpdate)
+                {
+                    ApplyServiceStatus(service, session.QueryStatus(service.Id));
+                }
+            });
+        }
+
+        // Queries the SCM directly over one connection; tracked service state is left untouched
+        public async Task<List<RunningServiceSnapshot>> GetRunningServicesSnapshotAsync()
+        {
+            List<string> serviceIds;
+            lock (_lock)
+            {
+                serviceIds = _services.Keys.ToList();
+            }
+
+            return await Task.Run(() =>
+            {
+                var snapshot = new List<RunningServiceSnapshot>();
+                using var session = OpenSCMSession();
+                foreach (var serviceId in serviceIds)
+                {
+                    var (_, pid) = session.QueryStatus(serviceId);
+                    if (pid == 0) continue;
+
+                    var entry = new RunningServiceSnapshot { ServiceId = serviceId, SCMServiceName = serviceId, Pid = pid };
+                    try
+                    {
+                        using var process = Process.GetProcessById(pid);
+                        entry.StartTime = process.StartTime;
+                    }
+                    catch (Exception)
+                    {
+                        // Exited since the query or not accessible; StartTime stays unset
+                    }
+                    snapshot.Add(entry);
+                }
+                return snapshot;
+            });
+        }
+
+        public SCMSession OpenSCMSession()
+        {
+            return new SCMSession();
         }
 
         public Task<List<Service>> GetServicesSnapshotAsync()
@@ -85,6 +194,18 @@ namespace Services.Core.Services
 
         public void Dispose()
         {
+            _metricsTimer?.Dispose();
+            _scheduleTimer?.Dispose();
+            _bandwidthTimer?.Dispose();
+            lock (_loadedProfiles)
+            {
+                foreach (var (token, profile) in _loadedProfiles.Values)
+                {
+                    TokenUtils.UnloadUserProfile(token, profile);
+                    ProcessUtils.CloseHandle(token);
+                }
+                _loadedProfiles.Clear();
+            }
             lock (_lock)
             {
                 foreach (var monitor in _monitors.Values)
@@ -126,37 +247,145 @@ namespace Services.Core.Services
                 WorkingDir = s.WorkingDir,
                 AutoStart = s.AutoStart,
                 AutoRestart = s.AutoRestart,
+                HandleThreshold = s.HandleThreshold,
+                PageFaultThreshold = s.PageFaultThreshold,
+                AffinityMask = s.AffinityMask,
+                MemoryLimitMB = s.MemoryLimitMB,
+                IconPath = s.IconPath,
+                Alias = s.Alias,
+                Permissions = s.Permissions,
+                PidHistory = s.PidHistory.ToList(),
+                ExitCodeHistory = s.ExitCodeHistory.ToList(),
+                WrapperNeedsUpdate = s.WrapperNeedsUpdate,
+                Trust = s.Trust,
+                Environment = s.Environment == null ? null : new Dictionary<string, string>(s.Environment, StringComparer.OrdinalIgnoreCase),
+                HandleLeakWarning = s.HandleLeakWarning,
+                DailyStartCount = s.DailyStartCount,
+                DailyStartCountDate = s.DailyStartCountDate,
+                DebugMode = s.DebugMode,
+                StartTimeout = s.StartTimeout,
+                StopTimeout = s.StopTimeout,
+                Timing = s.Timing == null ? null : new TimingDiagnostics
+                {
+                    StartLatencyMs = s.Timing.StartLatencyMs,
+                    StopLatencyMs = s.Timing.StopLatencyMs,
+                    AvgStartLatencyMs = s.Timing.AvgStartLatencyMs,
+                    MaxStartLatencyMs = s.Timing.MaxStartLatencyMs,
+                    StartSamples = s.Timing.StartSamples
+                },
+                LastRestartReason = s.LastRestartReason,
+                LastRestartInitiator = s.LastRestartInitiator,
                 CreatedAt = s.CreatedAt,
                 UpdatedAt = s.UpdatedAt
             };
         }
 
-        private async Task UpdateServiceStatusAsync(Service service)
+        // Raw view of what the wrapper reads; environment references in strings are left unexpanded
+        public Dictionary<string, RegistryValueInfo> GetServiceRegistryParameters(string serviceId)
         {
-            if (service == null) return;
-            await Task.Run(() =>
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+
+            var result = new Dictionary<string, RegistryValueInfo>(StringComparer.OrdinalIgnoreCase);
+            foreach (var name in paramsKey.GetValueNames())
             {
-                var (status, pid) = ServiceUtils.GetServiceStatus(service.Id);
-                
-                if (service.Status != status || service.Pid != pid)
-                {
-                    service.Status = status;
-                    service.Pid = pid;
-                    service.UpdatedAt = DateTime.Now;
-                    ServiceUpdated?.Invoke(this, CloneService(service));
-                }
-                else
+                result[name] = new RegistryValueInfo
                 {
-                    service.UpdatedAt = DateTime.Now;
-                }
-            });
+                    Kind = paramsKey.GetValueKind(name).ToString(),
+                    Value = paramsKey.GetValue(name, null, RegistryValueOptions.DoNotExpandEnvironmentNames)
+                };
+            }
+            return result;
+        }
+
+        // Stored under Parameters, so it lives as long as the SCM service: deleting and recreating the
+        // service yields a new ID. Services created before IDs existed are given one when loaded.
+        public string GetServiceManagedID(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            if (paramsKey.GetValue("ManagedID") is string managedId && Guid.TryParse(managedId, out _)) return managedId;
+            throw new Exception("Service has no managed ID");
+        }
+
+        private static void AssignManagedID(RegistryKey serviceKey, string serviceName)
+        {
+            try
+            {
+                using var paramsKey = serviceKey.OpenSubKey("Parameters", true);
+                paramsKey?.SetValue("ManagedID", Guid.NewGuid().ToString(), RegistryValueKind.String);
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to assign managed ID to {serviceName}: {ex.Message}");
+            }
+        }
+
+        public Service? FindServiceByManagedID(string managedId)
+        {
+            List<Service> services;
+            lock (_lock)
+            {
+                services = _services.Values.Select(CloneService).ToList();
+            }
+
+            foreach (var service in services)
+            {
+                using var paramsKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{service.Id}\Parameters");
+                if (paramsKey?.GetValue("ManagedID") is string id && string.Equals(id, managedId, StringComparison.OrdinalIgnoreCase))
+                    return service;
+            }
+            return null;
+        }
+
+        private static readonly TimeSpan StatusPollInterval = TimeSpan.FromMilliseconds(500);
+
+        // Queries the SCM on every poll rather than trusting the cached status
+        public async Task WaitForServiceStatusAsync(string serviceId, string targetStatus, TimeSpan timeout)
+        {
+            var service = GetTrackedService(serviceId);
+            var stopwatch = Stopwatch.StartNew();
+            while (true)
+            {
+                await UpdateServiceStatusAsync(service);
+                if (service.Status == targetStatus) return;
+                if (stopwatch.Elapsed >= timeout)
+                    throw new System.TimeoutException($"Service {serviceId} did not reach status {targetStatus} within {timeout.TotalSeconds:0.#}s (current: {service.Status})");
+                await Task.Delay(StatusPollInterval);
+            }
+        }
+
+        private async Task UpdateServiceStatusAsync(Service service)
+        {
+            if (service == null) return;
+            await Task.Run(() => ApplyServiceStatus(service, ServiceUtils.GetServiceStatus(service.Id)));
+        }
+
+        private void ApplyServiceStatus(Service service, (string Status, int Pid) current)
+        {
+            if (SetServiceStatus(service, current))
+                ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        // Updates the tracked state without raising ServiceUpdated; returns whether anything changed
+        private static bool SetServiceStatus(Service service, (string Status, int Pid) current)
+        {
+            var (status, pid) = current;
+            service.UpdatedAt = DateTime.Now;
+            if (service.Status == status && service.Pid == pid) return false;
+
+            service.Status = status;
+            service.Pid = pid;
+            return true;
         }
 
-        public async Task CreateServiceAsync(ServiceConfig config)
+        public async Task<Service> CreateServiceAsync(ServiceConfig config)
                 {
                     if (!File.Exists(config.ExePath))
                         throw new FileNotFoundException("Executable not found", config.ExePath);
 
+                    if (!string.IsNullOrEmpty(config.LogFile)) ValidateLogFilePath(config.LogFile);
+
                     // Security Validation
                     if (config.Name.Any(c => !char.IsLetterOrDigit(c) && c != '_' && c != '-' && c != ' '))
                         throw new ArgumentException("Service Name contains invalid characters.");
@@ -172,17 +401,15 @@ namespace Services.Core.Services
                         if (key != null) throw new Exception($"Service {serviceName} already exists");
                     }
 
-                    var module = Process.GetCurrentProcess().MainModule;
-                    if (module == null) throw new Exception("Cannot determine current executable path");
-                    string currentExe = module.FileName;
+                    string wrapperCmd = BuildWrapperCommand(serviceName);
 
-                    // Construct command safely
-                    string wrapperCmd = $"\"{currentExe}\" --service-wrapper \"{serviceName}\"";
+                    // SCM expects a double-null-terminated list of dependency names
+                    string? dependencies = config.Dependencies?.Count > 0 ? string.Join("\0", config.Dependencies) + "\0\0" : null;
 
                     // Use P/Invoke to create service
                     IntPtr scmHandle = ServiceUtils.OpenSCManager(null, null, ServiceUtils.SC_MANAGER_CREATE_SERVICE);
                     if (scmHandle == IntPtr.Zero)
-                        throw new Exception($"Failed to open SC Manager. Error: {Marshal.GetLastWin32Error()}");
+                        throw ServiceOperationException.FromLastError("Failed to open SC Manager");
 
                     try
                     {
@@ -197,12 +424,12 @@ namespace Services.Core.Services
                             wrapperCmd,
                             null,
                             IntPtr.Zero,
-                            null,
+                            dependencies,
                             null,
                             null);
 
                         if (serviceHandle == IntPtr.Zero)
-                            throw new Exception($"Failed to create service. Error: {Marshal.GetLastWin32Error()}");
+                            throw ServiceOperationException.FromLastError("Failed to create service");
 
                         ServiceUtils.CloseServiceHandle(serviceHandle);
                     }
@@ -229,8 +456,13 @@ namespace Services.Core.Services
                                             paramsKey.SetValue("WorkingDir", string.IsNullOrEmpty(config.WorkingDir) ? Path.GetDirectoryName(config.ExePath) ?? "" : config.WorkingDir);
                                             paramsKey.SetValue("DisplayName", config.Name);
                                             paramsKey.SetValue("AutoRestart", config.AutoRestart ? 1 : 0);
+                                            if (config.StartTimeout > TimeSpan.Zero) paramsKey.SetValue("StartTimeoutMs", (int)config.StartTimeout.TotalMilliseconds, RegistryValueKind.DWord);
+                                            if (config.StopTimeout > TimeSpan.Zero) paramsKey.SetValue("StopTimeoutMs", (int)config.StopTimeout.TotalMilliseconds, RegistryValueKind.DWord);
+                                            if (config.Environment?.Count > 0) paramsKey.SetValue("Environment", ServiceUtils.FormatEnvironmentLines(config.Environment), RegistryValueKind.MultiString);
+                                            if (!string.IsNullOrEmpty(config.LogFile)) paramsKey.SetValue("LogFile", config.LogFile, RegistryValueKind.String);
                                             paramsKey.SetValue("CreatedAt", DateTime.Now.ToString("o"));
                                             paramsKey.SetValue("ManagedBy", "WindowsServiceManager");
+                                            paramsKey.SetValue("ManagedID", Guid.NewGuid().ToString());
                                         }
                                     }
                                 }
@@ -252,182 +484,3631 @@ namespace Services.Core.Services
                     await RunCommandAsync("sc.exe", $"failure \"{serviceName}\" reset= 86400 actions= restart/60000/restart/60000/restart/60000");
 
                     await LoadServicesAsync();
+                    return CloneService(GetTrackedService(serviceName));
                 }
 
 
-        private async Task RunCommandAsync(string command, string args)
+        public async Task<string> ExportToTaskSchedulerAsync(string serviceId)
         {
-            var psi = new ProcessStartInfo(command, args)
+            var service = GetTrackedService(serviceId);
+
+            string? account;
+            using (var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}"))
             {
-                CreateNoWindow = true,
-                UseShellExecute = false,
-                RedirectStandardOutput = true,
-                RedirectStandardError = true
-            };
+                if (serviceKey == null) throw new Exception("Service not found in registry");
+                account = serviceKey.GetValue("ObjectName") as string;
+            }
 
-            using var p = Process.Start(psi);
-            if (p != null)
+            // Refuse rather than replace: ConvertServiceToTaskAsync deletes the task again if the conversion fails
+            string taskName = TaskSchedulerHelper.TaskFolder + service.Id;
+            if (await TaskExistsAsync(taskName))
+                throw new InvalidOperationException($"Scheduled task {taskName} already exists");
+
+            var xml = TaskSchedulerHelper.BuildBootTaskXml(
+                $"Exported from service {service.Name} by Windows Service Manager",
+                TaskSchedulerHelper.ToTaskUserId(account),
+                service.ExePath,
+                service.Args,
+                string.IsNullOrEmpty(service.WorkingDir) ? Path.GetDirectoryName(service.ExePath) : service.WorkingDir);
+
+            string xmlPath = TaskSchedulerHelper.WriteTaskXml(xml);
+            try
             {
-                await p.WaitForExitAsync();
-                if (p.ExitCode != 0)
-                {
-                    var err = await p.StandardError.ReadToEndAsync();
-                    throw new Exception($"Command failed: {command} {args}\nError: {err}");
-                }
+                await RunCommandAsync("schtasks.exe", $"/Create /TN \"{taskName}\" /XML \"{xmlPath}\" /F");
+            }
+            finally
+            {
+                try { File.Delete(xmlPath); } catch { }
             }
+
+            return taskName;
         }
 
-        private string GenerateServiceName(string displayName)
+        private async Task<bool> TaskExistsAsync(string taskName)
         {
-            var safe = new string(displayName.Where(c => char.IsLetterOrDigit(c)).ToArray());
-            return $"WinSvcMgr_{safe}_{Guid.NewGuid().ToString("N").Substring(0, 8)}";
+            try
+            {
+                await RunCommandAsync("schtasks.exe", $"/Query /TN \"{taskName}\"");
+                return true;
+            }
+            catch
+            {
+                return false;
+            }
         }
 
-        public async Task StartServiceAsync(string serviceId)
+        public async Task<TaskInfo> ConvertServiceToTaskAsync(string serviceId)
         {
-            Service? service;
-            lock (_lock)
+            string taskName = await ExportToTaskSchedulerAsync(serviceId);
+            try
             {
-                if (!_services.TryGetValue(serviceId, out service)) throw new Exception("Service not found");
+                await DeleteServiceAsync(serviceId);
             }
-
-            using var sc = new ServiceController(serviceId);
-            if (sc.Status != ServiceControllerStatus.Running)
+            catch
             {
-                sc.Start();
-                try
-                {
-                    sc.WaitForStatus(ServiceControllerStatus.Running, TimeSpan.FromSeconds(30));
-                }
-                catch (System.ServiceProcess.TimeoutException) { }
+                try { await RunCommandAsync("schtasks.exe", $"/Delete /TN \"{taskName}\" /F"); } catch { }
+                throw;
             }
-            await UpdateServiceStatusAsync(service);
-            ServiceUpdated?.Invoke(this, service);
+
+            return new TaskInfo { TaskName = serviceId, TaskPath = TaskSchedulerHelper.TaskFolder };
         }
 
-        public async Task StopServiceAsync(string serviceId)
+        public async Task<Service> ConvertTaskToServiceAsync(string taskName)
         {
-            Service? service;
-            lock (_lock)
+            var xml = await RunCommandAsync("schtasks.exe", $"/Query /TN \"{taskName}\" /XML");
+            var (exePath, args, workingDir) = TaskSchedulerHelper.ParseExecAction(XDocument.Parse(xml));
+
+            var service = await CreateServiceAsync(new ServiceConfig
             {
-                if (!_services.TryGetValue(serviceId, out service)) throw new Exception("Service not found");
-            }
+                Name = taskName.Split('\\', StringSplitOptions.RemoveEmptyEntries).Last(),
+                ExePath = exePath,
+                Args = args,
+                WorkingDir = workingDir
+            });
 
-            using var sc = new ServiceController(serviceId);
-            if (sc.Status == ServiceControllerStatus.Running)
+            try
             {
-                sc.Stop();
-                try
-                {
-                    sc.WaitForStatus(ServiceControllerStatus.Stopped, TimeSpan.FromSeconds(30));
-                }
-                catch (System.ServiceProcess.TimeoutException) { }
+                await RunCommandAsync("schtasks.exe", $"/Delete /TN \"{taskName}\" /F");
             }
-            await UpdateServiceStatusAsync(service);
-            ServiceUpdated?.Invoke(this, service);
+            catch
+            {
+                try { await DeleteServiceAsync(service.Id); } catch { }
+                throw;
+            }
+
+            return service;
         }
 
-        public async Task DeleteServiceAsync(string serviceId)
-                {
-                    lock (_lock)
-                    {
-                        if (!_services.ContainsKey(serviceId)) throw new Exception("Service not found");
+        private static readonly Regex ScFieldRegex = new(@"^\s*([A-Z_]+)\s*:\s?(.*)$", RegexOptions.Compiled);
+        private static readonly Regex ScContinuationRegex = new(@"^\s+:\s?(.*)$", RegexOptions.Compiled);
 
-                        // 清理 monitor
-                        if (_monitors.TryGetValue(serviceId, out var monitor))
-                        {
-                            monitor.Dispose();
-                            _monitors.Remove(serviceId);
-                        }
-                    }
+        // Parses the text printed by `sc qc <name>`
+        public static ServiceConfig ParseSCQueryOutput(string scOutput)
+        {
+            var fields = new Dictionary<string, List<string>>(StringComparer.OrdinalIgnoreCase);
+            string? lastField = null;
 
-                    await StopServiceAsync(serviceId);
+            foreach (var line in scOutput.Split('\n'))
+            {
+                var trimmedEnd = line.TrimEnd('\r', ' ');
+                var continuation = ScContinuationRegex.Match(trimmedEnd);
+                if (continuation.Success && lastField != null)
+                {
+                    fields[lastField].Add(continuation.Groups[1].Value.Trim());
+                    continue;
+                }
 
-                    // Use P/Invoke to delete service
-                    IntPtr scmHandle = ServiceUtils.OpenSCManager(null, null, ServiceUtils.SC_MANAGER_CONNECT);
-                    if (scmHandle == IntPtr.Zero)
-                        throw new Exception($"Failed to open SC Manager. Error: {Marshal.GetLastWin32Error()}");
+                var match = ScFieldRegex.Match(trimmedEnd);
+                if (!match.Success) continue;
 
-                    try
-                    {
-                        // We need DELETE access
-                        IntPtr serviceHandle = ServiceUtils.OpenService(scmHandle, serviceId, ServiceUtils.DELETE);
-                        if (serviceHandle == IntPtr.Zero)
-                            throw new Exception($"Failed to open service for deletion. Error: {Marshal.GetLastWin32Error()}");
+                lastField = match.Groups[1].Value;
+                fields[lastField] = new List<string> { match.Groups[2].Value.Trim() };
+            }
 
-                        try
-                        {
-                            if (!ServiceUtils.DeleteService(serviceHandle))
-                                throw new Exception($"Failed to delete service. Error: {Marshal.GetLastWin32Error()}");
-                        }
-                        finally
-                        {
-                            ServiceUtils.CloseServiceHandle(serviceHandle);
-                        }
-                    }
-                    finally
-                    {
-                        ServiceUtils.CloseServiceHandle(scmHandle);
-                    }
+            if (!fields.TryGetValue("BINARY_PATH_NAME", out var binaryPath) || string.IsNullOrEmpty(binaryPath[0]))
+                throw new FormatException("sc qc output does not contain BINARY_PATH_NAME");
 
-                    // Remove from managed services index
-                    RemoveFromManagedServicesIndex(serviceId);
+            var (exePath, args) = SplitCommandLine(binaryPath[0]);
+            if (args.Contains("--service-wrapper"))
+                throw new FormatException("The service is managed by a wrapper; its target program is not part of the sc qc output");
 
-                    // Clean up registry Parameters subkey
-                    try
-                    {
-                        using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}", writable: true);
-                        key?.DeleteSubKeyTree("Parameters", throwOnMissingSubKey: false);
-                    }
-                    catch (Exception ex)
-                    {
-                        System.Diagnostics.Debug.WriteLine($"Failed to delete Parameters registry key: {ex.Message}");
-                    }
+            string displayName = fields.TryGetValue("DISPLAY_NAME", out var dn) ? dn[0] : "";
+            if (string.IsNullOrEmpty(displayName) && fields.TryGetValue("SERVICE_NAME", out var sn)) displayName = sn[0];
+            // CreateServiceAsync only accepts letters, digits, spaces, '_' and '-'
+            displayName = new string(displayName.Where(c => char.IsLetterOrDigit(c) || c == '_' || c == '-' || c == ' ').ToArray()).Trim();
 
-                    lock (_lock)
-                    {
-                        _services.Remove(serviceId);
-                    }
-                }
+            var startType = fields.TryGetValue("START_TYPE", out var st) ? st[0] : "";
 
+            return new ServiceConfig
+            {
+                Name = string.IsNullOrEmpty(displayName) ? Path.GetFileNameWithoutExtension(exePath) : displayName,
+                ExePath = exePath,
+                Args = args,
+                StartupType = startType.Contains("AUTO_START") ? ServiceStartupType.Auto : ServiceStartupType.Manual,
+                Dependencies = fields.TryGetValue("DEPENDENCIES", out var deps) ? deps.Where(d => d.Length > 0).ToList() : null
+            };
+        }
 
-        private void AddToManagedServicesIndex(string serviceName)
+        public async Task<Service> ImportFromSCOutputAsync(string scOutput)
         {
-            try
-            {
-                using var hklm = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry64);
-                using var indexKey = hklm.CreateSubKey(@"SOFTWARE\WindowsServiceManager\ManagedServices");
-                using var serviceIndexKey = indexKey.CreateSubKey(serviceName);
-            }
-            catch (Exception ex)
-            {
-                System.Diagnostics.Debug.WriteLine($"Failed to add service to index: {ex.Message}");
-            }
+            return await CreateServiceAsync(ParseSCQueryOutput(scOutput));
         }
 
-        private void RemoveFromManagedServicesIndex(string serviceName)
+        public async Task<Service> ImportFromXmlFileAsync(string xmlPath)
         {
+            var definition = ServiceXmlHelper.Parse(XDocument.Load(xmlPath));
+            // Enum.TryParse also accepts numeric strings such as "7", so require a defined name
+            if (int.TryParse(definition.StartType, out _) ||
+                !Enum.TryParse<ServiceStartupType>(definition.StartType, true, out var startupType) ||
+                !Enum.IsDefined(startupType))
+                throw new FormatException($"Unsupported start type: {definition.StartType}");
+
+            // CreateServiceAsync only accepts letters, digits, spaces, '_' and '-'
+            var name = new string(definition.DisplayName.Where(c => char.IsLetterOrDigit(c) || c == '_' || c == '-' || c == ' ').ToArray()).Trim();
+            var service = await CreateServiceAsync(new ServiceConfig
+            {
+                Name = string.IsNullOrEmpty(name) ? Path.GetFileNameWithoutExtension(definition.Executable) : name,
+                ExePath = Environment.ExpandEnvironmentVariables(definition.Executable),
+                Args = definition.Arguments,
+                WorkingDir = definition.WorkingDirectory,
+                StartupType = startupType
+            });
+
             try
             {
-                using var hklm = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry64);
-                using var indexKey = hklm.OpenSubKey(@"SOFTWARE\WindowsServiceManager\ManagedServices", true);
-                indexKey?.DeleteSubKey(serviceName, false);
+                if (!string.IsNullOrEmpty(definition.Alias))
+                    SetServiceCustomAlias(service.Id, definition.Alias);
+
+                if (!string.IsNullOrEmpty(definition.Description))
+                    await RunCommandAsync("sc.exe", $"description \"{service.Id}\" \"{definition.Description.Replace("\"", "'")}\"");
+
+                // Replaces the default restart actions set by CreateServiceAsync
+                if (definition.RecoveryActions?.Actions.Count > 0)
+                {
+                    var recovery = definition.RecoveryActions;
+                    var command = string.IsNullOrEmpty(recovery.Command) ? "" : $" command= \"{recovery.Command.Replace("\"", "\\\"")}\"";
+                    await RunCommandAsync("sc.exe", $"failure \"{service.Id}\" reset= {recovery.ResetPeriodSeconds} actions= {ServiceXmlHelper.FormatScFailureActions(recovery)}{command}");
+                }
             }
-            catch (Exception ex)
+            catch
             {
-                System.Diagnostics.Debug.WriteLine($"Failed to remove service from index: {ex.Message}");
+                // Don't leave a half-configured service behind
+                try { await DeleteServiceAsync(service.Id); } catch { }
+                throw;
             }
+
+            return service;
         }
 
-        private async Task LoadServicesAsync()
+        public void ExportServiceToXml(string serviceId, string destPath)
         {
-            var services = new Dictionary<string, Service>();
-            await Task.Run(() =>
+            var service = GetTrackedService(serviceId);
+
+            using var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}");
+            if (serviceKey == null) throw new Exception("Service not found in registry");
+
+            var definition = new WindowsServiceXml
             {
-                try
+                Executable = service.ExePath,
+                Arguments = service.Args,
+                WorkingDirectory = service.WorkingDir,
+                DisplayName = service.Name,
+                Alias = service.Alias,
+                Description = serviceKey.GetValue("Description") as string,
+                StartType = serviceKey.GetValue("Start") switch
                 {
-                    using var hklm = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry64);
-                    using var indexKey = hklm.OpenSubKey(@"SOFTWARE\WindowsServiceManager\ManagedServices");
+                    int start when start == (int)ServiceStartupType.Manual => nameof(ServiceStartupType.Manual),
+                    int start when start == (int)ServiceStartupType.Disabled => nameof(ServiceStartupType.Disabled),
+                    _ => nameof(ServiceStartupType.Auto)
+                },
+                RecoveryActions = ServiceUtils.ParseFailureActions(serviceKey.GetValue("FailureActions") as byte[], serviceKey.GetValue("FailureCommand") as string)
+            };
+
+            ServiceXmlHelper.Build(definition).Save(destPath);
+        }
+
+        // Splits "C:\Program Files\app.exe" -x or C:\tools\app.exe -x into executable and arguments
+        private static (string ExePath, string Args) SplitCommandLine(string commandLine)
+        {
+            commandLine = commandLine.Trim();
+            if (commandLine.StartsWith('"'))
+            {
+                int end = commandLine.IndexOf('"', 1);
+                if (end > 0) return (commandLine.Substring(1, end - 1), commandLine.Substring(end + 1).Trim());
+            }
+
+            int exeEnd = commandLine.IndexOf(".exe", StringComparison.OrdinalIgnoreCase);
+            if (exeEnd > 0) exeEnd += 4;
+            else exeEnd = commandLine.IndexOf(' ') is var space && space > 0 ? space : commandLine.Length;
+
+            return (commandLine.Substring(0, exeEnd), commandLine.Substring(exeEnd).Trim());
+        }
+
+        private async Task<string> RunCommandAsync(string command, string args)
+        {
+            var psi = new ProcessStartInfo(command, args)
+            {
+                CreateNoWindow = true,
+                UseShellExecute = false,
+                RedirectStandardOutput = true,
+                RedirectStandardError = true
+            };
+
+            using var p = Process.Start(psi);
+            if (p == null) return string.Empty;
+
+            var outputTask = p.StandardOutput.ReadToEndAsync();
+            var errorTask = p.StandardError.ReadToEndAsync();
+            await p.WaitForExitAsync();
+
+            if (p.ExitCode != 0)
+            {
+                var err = await errorTask;
+                throw new Exception($"Command failed: {command} {args}\nError: {err}");
+            }
+            return await outputTask;
+        }
+
+        private string GenerateServiceName(string displayName)
+        {
+            var safe = new string(displayName.Where(c => char.IsLetterOrDigit(c)).ToArray());
+            return $"WinSvcMgr_{safe}_{Guid.NewGuid().ToString("N").Substring(0, 8)}";
+        }
+
+        // Command line the wrapper runs for the target process, suitable for pasting into cmd.exe.
+        // Variables the wrapper injects from Parameters\Environment are prefixed as set commands;
+        // the quoted form keeps cmd from adding the space before && to the value.
+        public string GetServiceCommandLine(string serviceId)
+        {
+            var service = GetTrackedService(serviceId);
+
+            string exe = service.ExePath.Contains(' ') ? $"\"{service.ExePath}\"" : service.ExePath;
+            string command = string.IsNullOrWhiteSpace(service.Args) ? exe : $"{exe} {service.Args}";
+
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            var environment = ServiceUtils.ParseEnvironmentLines(paramsKey.GetValue("Environment") as string[]);
+            var prefix = environment
+                .OrderBy(kv => kv.Key, StringComparer.OrdinalIgnoreCase)
+                .Select(kv => $"set \"{kv.Key}={kv.Value}\" && ");
+            return string.Concat(prefix) + command;
+        }
+
+        public async Task<ServiceDetails> GetServiceDetailsAsync(string serviceId)
+        {
+            var service = CloneService(GetTrackedService(serviceId));
+
+            return await Task.Run(() =>
+            {
+                var details = new ServiceDetails { Service = service };
+                var (restartReason, restartInitiator) = GetServiceLastRestartReason(serviceId);
+                service.LastRestartReason = restartReason;
+                service.LastRestartInitiator = restartInitiator;
+
+                using (var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}"))
+                {
+                    if (serviceKey == null) throw new Exception("Service not found in registry");
+
+                    int start = serviceKey.GetValue("Start") is int st ? st : -1;
+                    bool delayed = serviceKey.GetValue("DelayedAutostart") is int d && d == 1;
+                    details.SCMStartType = ServiceUtils.FormatStartType(start, delayed);
+                    details.SCMDescription = serviceKey.GetValue("Description") as string ?? "";
+                    details.SCMBinaryPath = serviceKey.GetValue("ImagePath", "", RegistryValueOptions.DoNotExpandEnvironmentNames) as string ?? "";
+                    details.SCMDependencies = (serviceKey.GetValue("DependOnService") as string[])?.ToList() ?? new List<string>();
+                    details.SCMRunAsUser = serviceKey.GetValue("ObjectName") as string ?? "LocalSystem";
+                    details.SCMFailureActions = ServiceUtils.ParseFailureActions(serviceKey.GetValue("FailureActions") as byte[], serviceKey.GetValue("FailureCommand") as string);
+                    details.SCMSIDType = ServiceUtils.FormatSidType(serviceKey.GetValue("ServiceSidType") is int sid ? sid : 0);
+                    details.SCMRequiredPrivileges = (serviceKey.GetValue("RequiredPrivileges") as string[])?.ToList() ?? new List<string>();
+                }
+
+                details.BinaryInfo = GetBinaryInfo(service.ExePath);
+                details.ProcessMetrics = GetProcessMetrics(ResolveTargetPid(service));
+                return details;
+            });
+        }
+
+        private static BinaryInfo? GetBinaryInfo(string exePath)
+        {
+            if (!File.Exists(exePath)) return null;
+
+            var fileInfo = new FileInfo(exePath);
+            var versionInfo = FileVersionInfo.GetVersionInfo(exePath);
+            return new BinaryInfo
+            {
+                Path = exePath,
+                SizeBytes = fileInfo.Length,
+                LastModified = fileInfo.LastWriteTime,
+                FileVersion = versionInfo.FileVersion,
+                ProductVersion = versionInfo.ProductVersion,
+                ProductName = versionInfo.ProductName,
+                CompanyName = versionInfo.CompanyName,
+                FileDescription = versionInfo.FileDescription
+            };
+        }
+
+        private static ServiceMetrics? GetProcessMetrics(int pid)
+        {
+            if (pid == 0) return null;
+
+            try
+            {
+                using var process = Process.GetProcessById(pid);
+                return new ServiceMetrics
+                {
+                    Pid = pid,
+                    WorkingSetMB = process.WorkingSet64 / 1024.0 / 1024.0,
+                    PrivateMemoryMB = process.PrivateMemorySize64 / 1024.0 / 1024.0,
+                    HandleCount = process.HandleCount,
+                    ThreadCount = process.Threads.Count,
+                    StartTime = process.StartTime,
+                    TotalProcessorTime = process.TotalProcessorTime
+                };
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to read process metrics for {pid}: {ex.Message}");
+                return null;
+            }
+        }
+
+        // Local QoS policies live under the policy hive; a domain Group Policy refresh can overwrite or remove them
+        private const string QosPolicyKey = @"SOFTWARE\Policies\Microsoft\Windows\QoS";
+
+        public void SetServiceNetworkPriority(string serviceId, byte dscp)
+        {
+            if (dscp > 63) throw new ArgumentOutOfRangeException(nameof(dscp), "DSCP value must be between 0 and 63");
+
+            var exeName = Path.GetFileName(GetTrackedService(serviceId).ExePath);
+            using var policyKey = Registry.LocalMachine.CreateSubKey($@"{QosPolicyKey}\{exeName}");
+            policyKey.SetValue("Version", "1.0");
+            policyKey.SetValue("Application Name", exeName);
+            policyKey.SetValue("Protocol", "*");
+            policyKey.SetValue("Local Port", "*");
+            policyKey.SetValue("Local IP", "*");
+            policyKey.SetValue("Local IP Prefix Length", "*");
+            policyKey.SetValue("Remote Port", "*");
+            policyKey.SetValue("Remote IP", "*");
+            policyKey.SetValue("Remote IP Prefix Length", "*");
+            policyKey.SetValue("DSCP Value", dscp.ToString());
+            policyKey.SetValue("Throttle Rate", "-1");
+        }
+
+        public byte GetServiceNetworkPriority(string serviceId)
+        {
+            var exeName = Path.GetFileName(GetTrackedService(serviceId).ExePath);
+            using var policyKey = Registry.LocalMachine.OpenSubKey($@"{QosPolicyKey}\{exeName}");
+            if (policyKey == null) throw new Exception("No network priority policy configured for this service");
+
+            if (!byte.TryParse(policyKey.GetValue("DSCP Value") as string, out var dscp))
+                throw new Exception("Network priority policy has an invalid DSCP value");
+            return dscp;
+        }
+
+        public void DeleteServiceNetworkPriority(string serviceId)
+        {
+            var exeName = Path.GetFileName(GetTrackedService(serviceId).ExePath);
+            using var qosKey = Registry.LocalMachine.OpenSubKey(QosPolicyKey, true);
+            qosKey?.DeleteSubKeyTree(exeName, throwOnMissingSubKey: false);
+        }
+
+        public List<string> GetServiceDependencies(string serviceName)
+        {
+            using var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceName}");
+            if (serviceKey == null) throw new Exception($"Service {serviceName} not found");
+            return (serviceKey.GetValue("DependOnService") as string[])?.Where(d => !string.IsNullOrWhiteSpace(d)).ToList() ?? new List<string>();
+        }
+
+        // Orders the given services so that every service comes after the services it (transitively) depends on
+        public List<string> GetServiceDependencyChain(IEnumerable<string> serviceIds)
+        {
+            var ids = serviceIds.ToList();
+            var requested = new HashSet<string>(ids, StringComparer.OrdinalIgnoreCase);
+            var visited = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
+            var path = new List<string>();
+            var order = new List<string>();
+
+            void Visit(string name)
+            {
+                int inPath = path.FindIndex(p => string.Equals(p, name, StringComparison.OrdinalIgnoreCase));
+                if (inPath >= 0)
+                    throw new CyclicDependencyException(path.Skip(inPath).Append(name).ToList());
+                if (!visited.Add(name)) return;
+
+                path.Add(name);
+                List<string> dependencies;
+                try
+                {
+                    dependencies = GetServiceDependencies(name);
+                }
+                catch (Exception) when (!requested.Contains(name))
+                {
+                    // Missing transitive dependencies are reported when the service is started
+                    dependencies = new List<string>();
+                }
+
+                foreach (var dependency in dependencies)
+                {
+                    Visit(dependency);
+                }
+                path.RemoveAt(path.Count - 1);
+
+                if (requested.Contains(name)) order.Add(name);
+            }
+
+            foreach (var id in ids)
+            {
+                Visit(id);
+            }
+            return order;
+        }
+
+        private const int MaxDependencyGraphDepth = 3;
+
+        // Flat node list starting at the managed services and following SCM dependencies into system services
+        public List<DependencyNode> GetFullDependencyGraph()
+        {
+            Dictionary<string, Service> managed;
+            lock (_lock)
+            {
+                managed = _services.Values.ToDictionary(s => s.Id, CloneService, StringComparer.OrdinalIgnoreCase);
+            }
+
+            var nodes = new Dictionary<string, DependencyNode>(StringComparer.OrdinalIgnoreCase);
+            using var session = OpenSCMSession();
+            var queue = new Queue<(string Name, int Depth)>(managed.Keys.Select(id => (id, 0)));
+
+            while (queue.Count > 0)
+            {
+                var (name, depth) = queue.Dequeue();
+                if (nodes.ContainsKey(name)) continue;
+
+                var node = new DependencyNode { ServiceName = name, IsManaged = managed.ContainsKey(name) };
+                nodes[name] = node;
+
+                if (node.IsManaged)
+                {
+                    node.DisplayName = managed[name].Name;
+                    node.Status = managed[name].Status;
+                }
+                else
+                {
+                    try
+                    {
+                        using var sc = new ServiceController(name);
+                        node.DisplayName = sc.DisplayName;
+                    }
+                    catch (Exception)
+                    {
+                        node.DisplayName = name;
+                    }
+                    node.Status = session.QueryStatus(name).Status;
+                }
+
+                if (depth >= MaxDependencyGraphDepth) continue;
+
+                try
+                {
+                    node.Children = GetServiceDependencies(name);
+                }
+                catch (Exception ex)
+                {
+                    System.Diagnostics.Debug.WriteLine($"Failed to read dependencies of {name}: {ex.Message}");
+                }
+
+                foreach (var child in node.Children)
+                {
+                    queue.Enqueue((child, depth + 1));
+                }
+            }
+
+            return nodes.Values.ToList();
+        }
+
+        private const string ProfileListKey = @"SOFTWARE\Microsoft\Windows NT\CurrentVersion\ProfileList";
+
+        private static string GetServiceAccount(string serviceId)
+        {
+            using var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}");
+            if (serviceKey == null) throw new Exception("Service not found in registry");
+            return serviceKey.GetValue("ObjectName") as string ?? "LocalSystem";
+        }
+
+        private static string GetAccountSid(string account)
+        {
+            var sid = TaskSchedulerHelper.ToTaskUserId(account);
+            if (sid.StartsWith("S-1-", StringComparison.Ordinal)) return sid;
+
+            var name = account.StartsWith(@".\", StringComparison.Ordinal) ? $@"{Environment.MachineName}\{account[2..]}" : account;
+            return new NTAccount(name).Translate(typeof(SecurityIdentifier)).Value;
+        }
+
+        // A profile is loaded when its hive is mounted under HKEY_USERS\<SID>
+        public UserProfileStatus GetServiceUserProfileStatus(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            var account = GetServiceAccount(serviceId);
+            var sid = GetAccountSid(account);
+
+            var status = new UserProfileStatus { Username = account };
+            using (var profileKey = Registry.LocalMachine.OpenSubKey($@"{ProfileListKey}\{sid}"))
+            {
+                var path = profileKey?.GetValue("ProfileImagePath") as string;
+                if (path != null)
+                {
+                    status.ProfilePath = Environment.ExpandEnvironmentVariables(path);
+                    status.IsLocalProfile = !status.ProfilePath.StartsWith(@"\\", StringComparison.Ordinal) && Directory.Exists(status.ProfilePath);
+                }
+            }
+
+            using (var hive = Registry.Users.OpenSubKey(sid))
+            {
+                status.ProfileLoaded = hive != null;
+            }
+            return status;
+        }
+
+        // Needs the account password stored by SetServiceRunAs; built-in accounts always have their profile loaded
+        public void LoadServiceUserProfile(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            lock (_loadedProfiles)
+            {
+                if (_loadedProfiles.ContainsKey(serviceId)) return;
+            }
+
+            var account = GetServiceAccount(serviceId);
+            if (TaskSchedulerHelper.ToTaskUserId(account).StartsWith("S-1-5-", StringComparison.Ordinal))
+                throw new InvalidOperationException($"Profile of built-in account {account} is managed by the system");
+
+            var (username, password) = GetSecureCredential(GetRunAsCredentialTarget(serviceId));
+            var parts = username.Split('\\', 2);
+            string? domain = parts.Length == 2 ? parts[0] : null;
+            string user = parts[^1];
+
+            if (!TokenUtils.LogonUser(user, domain, password, TokenUtils.LOGON32_LOGON_SERVICE, TokenUtils.LOGON32_PROVIDER_DEFAULT, out var token))
+                throw ServiceOperationException.FromLastError($"Failed to log on as {username}");
+
+            var profileInfo = new TokenUtils.PROFILEINFO { dwSize = Marshal.SizeOf<TokenUtils.PROFILEINFO>(), lpUserName = user };
+            if (!TokenUtils.LoadUserProfile(token, ref profileInfo))
+            {
+                var error = ServiceOperationException.FromLastError($"Failed to load profile of {username}");
+                ProcessUtils.CloseHandle(token);
+                throw error;
+            }
+
+            lock (_loadedProfiles)
+            {
+                _loadedProfiles[serviceId] = (token, profileInfo.hProfile);
+            }
+        }
+
+        public void UnloadServiceUserProfile(string serviceId)
+        {
+            (IntPtr Token, IntPtr Profile) loaded;
+            lock (_loadedProfiles)
+            {
+                if (!_loadedProfiles.Remove(serviceId, out loaded))
+                    throw new InvalidOperationException("Profile was not loaded by this manager");
+            }
+
+            try
+            {
+                if (!TokenUtils.UnloadUserProfile(loaded.Token, loaded.Profile))
+                    throw ServiceOperationException.FromLastError("Failed to unload user profile");
+            }
+            finally
+            {
+                ProcessUtils.CloseHandle(loaded.Token);
+            }
+        }
+
+        // Picked up by the wrapper within its request poll interval
+        public void RotateServiceLog(string serviceId)
+        {
+            if (ResolveTargetPid(GetTrackedService(serviceId)) == 0) throw new Exception("Service is not running");
+
+            using var paramsKey = OpenParametersKey(serviceId, true);
+            paramsKey.SetValue("RotateLogRequest", "1", RegistryValueKind.String);
+        }
+
+        private static readonly TimeSpan DNSTestTimeout = TimeSpan.FromSeconds(15);
+
+        // The wrapper polls for the request and resolves the name as the service account. It writes the
+        // result back to Parameters, which SetServiceRunAs opens up to non-admin accounts.
+        public void RequestDNSTest(string serviceId, string hostname)
+        {
+            if (ResolveTargetPid(GetTrackedService(serviceId)) == 0) throw new Exception("Service is not running");
+
+            using var paramsKey = OpenParametersKey(serviceId, true);
+            paramsKey.DeleteValue("DNSTestResult", false);
+            paramsKey.SetValue("DNSTestRequest", hostname, RegistryValueKind.String);
+        }
+
+        public async Task<DNSTestResult> TestServiceDNSResolutionAsync(string serviceId, string hostname)
+        {
+            RequestDNSTest(serviceId, hostname);
+
+            var stopwatch = Stopwatch.StartNew();
+            while (stopwatch.Elapsed < DNSTestTimeout)
+            {
+                await Task.Delay(500);
+                using var paramsKey = OpenParametersKey(serviceId, false);
+                if (paramsKey.GetValue("DNSTestResult") is string json &&
+                    JsonSerializer.Deserialize<DNSTestResult>(json) is { } result &&
+                    string.Equals(result.Hostname, hostname, StringComparison.OrdinalIgnoreCase))
+                {
+                    return result;
+                }
+            }
+            throw new System.TimeoutException("The service wrapper did not answer the DNS test request");
+        }
+
+        public TokenInfo GetServiceWindowsTokenInfo(string serviceId)
+        {
+            int pid = ResolveTargetPid(GetTrackedService(serviceId));
+            if (pid == 0) throw new Exception("Service is not running");
+
+            IntPtr hToken = TokenUtils.OpenProcessTokenForQuery(pid);
+            try
+            {
+                using var identity = new WindowsIdentity(hToken);
+                var sid = identity.User?.Value ?? "";
+                var nameParts = identity.Name.Split('\\', 2);
+
+                var info = new TokenInfo
+                {
+                    SID = sid,
+                    Domain = nameParts.Length == 2 ? nameParts[0] : "",
+                    Username = nameParts[^1],
+                    IsLocalSystem = sid == "S-1-5-18",
+                    IsLocalService = sid == "S-1-5-19",
+                    IsNetworkService = sid == "S-1-5-20"
+                };
+
+                foreach (var group in identity.Groups ?? new IdentityReferenceCollection())
+                {
+                    try
+                    {
+                        info.Groups.Add(group.Translate(typeof(NTAccount)).Value);
+                    }
+                    catch (IdentityNotMappedException)
+                    {
+                        info.Groups.Add(group.Value);
+                    }
+                }
+
+                info.Privileges = TokenUtils.GetPrivileges(hToken).Select(p => p.Name).ToList();
+                return info;
+            }
+            finally
+            {
+                ProcessUtils.CloseHandle(hToken);
+            }
+        }
+
+        public EffectiveTokenSummary GetServiceEffectiveToken(string serviceId)
+        {
+            int pid = ResolveTargetPid(GetTrackedService(serviceId));
+            if (pid == 0) throw new Exception("Service is not running");
+
+            IntPtr hToken = TokenUtils.OpenProcessTokenForQuery(pid);
+            try
+            {
+                uint integrity = TokenUtils.GetIntegrityLevel(hToken);
+                uint policy = TokenUtils.GetMandatoryPolicy(hToken);
+                return new EffectiveTokenSummary
+                {
+                    LogonType = GetTokenLogonType(hToken),
+                    Elevation = TokenUtils.GetElevationType(hToken) switch
+                    {
+                        2 => "full",
+                        3 => "limited",
+                        _ => "default"
+                    },
+                    IntegrityLevel = integrity,
+                    MandatoryLabel = integrity switch
+                    {
+                        >= 0x4000 => "system",
+                        >= 0x3000 => "high",
+                        >= 0x2000 => "medium",
+                        >= 0x1000 => "low",
+                        _ => "untrusted"
+                    },
+                    IsRestricted = TokenUtils.IsTokenRestricted(hToken),
+                    NoWriteUp = (policy & TokenUtils.TOKEN_MANDATORY_POLICY_NO_WRITE_UP) != 0,
+                    NewProcessMin = (policy & TokenUtils.TOKEN_MANDATORY_POLICY_NEW_PROCESS_MIN) != 0
+                };
+            }
+            finally
+            {
+                ProcessUtils.CloseHandle(hToken);
+            }
+        }
+
+        // The logon type is recorded as a well-known group SID in the token. SCM tokens for LocalSystem carry
+        // none of them, so the built-in service accounts are reported as "service".
+        private static string GetTokenLogonType(IntPtr hToken)
+        {
+            using var identity = new WindowsIdentity(hToken);
+            var groups = (identity.Groups ?? new IdentityReferenceCollection()).Select(g => g.Value).ToHashSet();
+            if (groups.Contains("S-1-5-6")) return "service";
+            if (groups.Contains("S-1-5-3")) return "batch";
+            if (groups.Contains("S-1-5-4")) return "interactive";
+            if (groups.Contains("S-1-5-2")) return "network";
+            return identity.User?.Value is "S-1-5-18" or "S-1-5-19" or "S-1-5-20" ? "service" : "unknown";
+        }
+
+        // Privileges present in the running process token, as opposed to the SCM required-privileges setting
+        public List<TokenPrivilege> GetServiceTokenPrivileges(string serviceId)
+        {
+            int pid = ResolveTargetPid(GetTrackedService(serviceId));
+            if (pid == 0) throw new Exception("Service is not running");
+
+            IntPtr hToken = TokenUtils.OpenProcessTokenForQuery(pid);
+            try
+            {
+                return TokenUtils.GetPrivileges(hToken).Select(p => new TokenPrivilege
+                {
+                    Name = p.Name,
+                    LUID = $"{p.Luid.HighPart:X8}:{p.Luid.LowPart:X8}",
+                    Attributes = (p.Attributes & TokenUtils.SE_PRIVILEGE_ENABLED) == 0 ? "disabled"
+                        : (p.Attributes & TokenUtils.SE_PRIVILEGE_ENABLED_BY_DEFAULT) != 0 ? "default-enabled"
+                        : "enabled"
+                }).ToList();
+            }
+            finally
+            {
+                ProcessUtils.CloseHandle(hToken);
+            }
+        }
+
+        public async Task<List<ServiceStartOrderInfo>> GetServiceAutoStartOrderAsync()
+        {
+            return await Task.Run(() =>
+            {
+                var infos = new Dictionary<string, ServiceStartOrderInfo>(StringComparer.OrdinalIgnoreCase);
+
+                using (var servicesKey = Registry.LocalMachine.OpenSubKey(@"SYSTEM\CurrentControlSet\Services"))
+                {
+                    if (servicesKey == null) throw new Exception("Cannot open services registry key");
+
+                    foreach (var name in servicesKey.GetSubKeyNames())
+                    {
+                        using var serviceKey = servicesKey.OpenSubKey(name);
+                        if (serviceKey == null) continue;
+
+                        // Only Win32 services (own/shared process) that start automatically
+                        int type = serviceKey.GetValue("Type") is int t ? t : 0;
+                        int start = serviceKey.GetValue("Start") is int st ? st : -1;
+                        if ((type & 0x30) == 0 || start != 2) continue;
+
+                        bool delayed = serviceKey.GetValue("DelayedAutostart") is int d && d == 1;
+                        var displayName = serviceKey.GetValue("DisplayName") as string ?? name;
+                        infos[name] = new ServiceStartOrderInfo
+                        {
+                            ServiceId = name,
+                            ServiceName = displayName.StartsWith('@') ? name : displayName,
+                            StartType = ServiceUtils.FormatStartType(start, delayed),
+                            Dependencies = (serviceKey.GetValue("DependOnService") as string[])?.Where(x => !string.IsNullOrWhiteSpace(x)).ToList() ?? new List<string>()
+                        };
+                    }
+                }
+
+                var ordered = GetServiceDependencyChain(infos.Keys);
+
+                // Delayed auto-start services are started after all regular auto-start services
+                var sequence = ordered.Where(n => infos[n].StartType == "Automatic")
+                    .Concat(ordered.Where(n => infos[n].StartType != "Automatic"))
+                    .ToList();
+
+                for (int i = 0; i < sequence.Count; i++)
+                {
+                    infos[sequence[i]].EstimatedStartOrder = i + 1;
+                }
+                return sequence.Select(n => infos[n]).ToList();
+            });
+        }
+
+        // Measured from this boot's "running" state changes: each service is charged the gap since the previous
+        // service reached running. Services that have not started since boot report 0.
+        public async Task<List<StartupImpactInfo>> GetServiceStartupImpactAsync()
+        {
+            var order = await GetServiceAutoStartOrderAsync();
+
+            return await Task.Run(() =>
+            {
+                var bootTime = DateTime.Now - TimeSpan.FromMilliseconds(Environment.TickCount64);
+                var runningAt = new Dictionary<string, DateTime>(StringComparer.OrdinalIgnoreCase);
+                EventLogHelper.ReadEntries("System", bootTime, e =>
+                {
+                    // Entries arrive newest-first, so the last write per service is its first start after boot
+                    if (e.Source == "Service Control Manager" &&
+                        EventLogHelper.TryParseServiceStateChange(e, out var name, out var state) &&
+                        state == EventLogHelper.SERVICE_RUNNING)
+                    {
+                        runningAt[name] = e.TimeGenerated;
+                    }
+                    return false;
+                });
+
+                var delays = new Dictionary<string, int>(StringComparer.OrdinalIgnoreCase);
+                DateTime? previous = null;
+                foreach (var (name, time) in runningAt.OrderBy(kv => kv.Value))
+                {
+                    delays[name] = previous.HasValue ? (int)(time - previous.Value).TotalMilliseconds : 0;
+                    previous = time;
+                }
+
+                return order.Select(info => new StartupImpactInfo
+                    {
+                        ServiceId = info.ServiceId,
+                        ServiceName = info.ServiceName,
+                        StartType = info.StartType,
+                        StartOrder = info.EstimatedStartOrder,
+                        EstimatedStartDelayMs = delays.TryGetValue(info.ServiceId, out var delay) ? delay : 0
+                    })
+                    .OrderByDescending(i => i.EstimatedStartDelayMs)
+                    .ThenBy(i => i.StartOrder)
+                    .ToList();
+            });
+        }
+
+        public List<Service> SortServiceList(string by, bool ascending)
+        {
+            List<Service> services;
+            lock (_lock)
+            {
+                services = _services.Values.Select(CloneService).ToList();
+            }
+
+            // OrderBy is a stable sort, so equal keys keep their original order
+            IOrderedEnumerable<Service> sorted = by switch
+            {
+                "name" => Order(services, s => s.Name, StringComparer.CurrentCultureIgnoreCase, ascending),
+                "status" => Order(services, s => s.Status, StringComparer.CurrentCulture, ascending),
+                "created" => Order(services, s => s.CreatedAt, Comparer<DateTime>.Default, ascending),
+                "updated" => Order(services, s => s.UpdatedAt, Comparer<DateTime>.Default, ascending),
+                "uptime" => Order(services, GetUptime, Comparer<TimeSpan>.Default, ascending),
+                "restartCount" => Order(services, s => ReadRestartCount(s.Id), Comparer<int>.Default, ascending),
+                "pid" => Order(services, s => s.Pid, Comparer<int>.Default, ascending),
+                _ => throw new ArgumentException($"Unknown sort field: {by}")
+            };
+            return sorted.ToList();
+        }
+
+        private static IOrderedEnumerable<Service> Order<TKey>(IEnumerable<Service> services, Func<Service, TKey> key, IComparer<TKey> comparer, bool ascending)
+        {
+            return ascending ? services.OrderBy(key, comparer) : services.OrderByDescending(key, comparer);
+        }
+
+        private static TimeSpan GetUptime(Service service)
+        {
+            if (service.Pid == 0) return TimeSpan.Zero;
+            try
+            {
+                using var process = Process.GetProcessById(service.Pid);
+                return DateTime.Now - process.StartTime;
+            }
+            catch (Exception)
+            {
+                return TimeSpan.Zero;
+            }
+        }
+
+        private static int ReadRestartCount(string serviceId)
+        {
+            try
+            {
+                using var paramsKey = OpenParametersKey(serviceId, false);
+                return paramsKey.GetValue("RestartCount") is int count ? count : 0;
+            }
+            catch (Exception)
+            {
+                return 0;
+            }
+        }
+
+        // WER event 1001 insertion strings: bucket ID, bucket type, event name, response, cab ID, then P1 (exe name), P2 (exe version), ...
+        public async Task<List<FaultBucket>> GetServiceFaultBucketsAsync(string serviceId, DateTime since)
+        {
+            var exeName = Path.GetFileName(GetTrackedService(serviceId).ExePath);
+
+            return await Task.Run(() =>
+            {
+                var entries = EventLogHelper.ReadEntries("Application", since, e =>
+                    e.Source == "Windows Error Reporting" &&
+                    EventLogHelper.GetEventId(e) == 1001 &&
+                    e.ReplacementStrings.Length > 6 &&
+                    string.Equals(e.ReplacementStrings[5], exeName, StringComparison.OrdinalIgnoreCase));
+
+                return entries.Select(e => new FaultBucket
+                {
+                    BucketId = e.ReplacementStrings[0],
+                    Timestamp = e.TimeGenerated,
+                    CrashType = e.ReplacementStrings[2],
+                    ExeVersion = e.ReplacementStrings[6]
+                }).ToList();
+            });
+        }
+
+        public void SetServiceDisplayIcon(string serviceId, string iconPath)
+        {
+            var service = GetTrackedService(serviceId);
+            if (!string.IsNullOrEmpty(iconPath) && !File.Exists(iconPath))
+                throw new FileNotFoundException("Icon file not found", iconPath);
+
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                if (string.IsNullOrEmpty(iconPath)) paramsKey.DeleteValue("IconPath", false);
+                else paramsKey.SetValue("IconPath", iconPath, RegistryValueKind.String);
+            }
+            service.IconPath = string.IsNullOrEmpty(iconPath) ? null : iconPath;
+            service.UpdatedAt = DateTime.Now;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        private static readonly Regex AliasRegex = new(@"^[A-Za-z0-9_.-]{1,64}$", RegexOptions.Compiled);
+
+        // An empty alias removes it; aliases are unique across managed services. The alias is registered
+        // as an Application event source so the wrapper can log under it from its next start.
+        public void SetServiceCustomAlias(string serviceId, string alias)
+        {
+            var service = GetTrackedService(serviceId);
+            if (!string.IsNullOrEmpty(alias))
+            {
+                if (!AliasRegex.IsMatch(alias))
+                    throw new ArgumentException("Alias may only contain letters, digits, '_', '.' and '-' (max 64 characters)");
+                var existing = FindServiceByAlias(alias);
+                if (existing != null && existing.Id != serviceId)
+                    throw new ArgumentException($"Alias {alias} is already used by {existing.Id}");
+            }
+
+            if (!string.IsNullOrEmpty(alias) && !EventLog.SourceExists(alias))
+                EventLog.CreateEventSource(alias, "Application");
+
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                if (string.IsNullOrEmpty(alias)) paramsKey.DeleteValue("CustomAlias", false);
+                else paramsKey.SetValue("CustomAlias", alias, RegistryValueKind.String);
+            }
+            service.Alias = string.IsNullOrEmpty(alias) ? null : alias;
+            service.UpdatedAt = DateTime.Now;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        public string? GetServiceAlias(string serviceId)
+        {
+            return GetTrackedService(serviceId).Alias;
+        }
+
+        public Service? FindServiceByAlias(string alias)
+        {
+            lock (_lock)
+            {
+                var service = _services.Values.FirstOrDefault(s => string.Equals(s.Alias, alias, StringComparison.OrdinalIgnoreCase));
+                return service == null ? null : CloneService(service);
+            }
+        }
+
+        // Picked up by a running wrapper within its request poll interval
+        public void SetServiceDebugMode(string serviceId, bool enabled)
+        {
+            var service = GetTrackedService(serviceId);
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                paramsKey.SetValue("DebugMode", enabled ? 1 : 0, RegistryValueKind.DWord);
+            }
+            service.DebugMode = enabled;
+            service.UpdatedAt = DateTime.Now;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        public bool GetServiceDebugMode(string serviceId)
+        {
+            var service = GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            service.DebugMode = paramsKey.GetValue("DebugMode") is int debug && debug == 1;
+            return service.DebugMode;
+        }
+
+        // Display names that are shared by more than one managed service, compared case-insensitively
+        public Dictionary<string, List<string>> GetDuplicateServiceNames()
+        {
+            lock (_lock)
+            {
+                return _services.Values
+                    .GroupBy(s => s.Name, StringComparer.OrdinalIgnoreCase)
+                    .Where(g => g.Count() > 1)
+                    .ToDictionary(g => g.Key, g => g.Select(s => s.Id).ToList(), StringComparer.OrdinalIgnoreCase);
+            }
+        }
+
+        public List<Service> ListServicesByBinaryDirectory(string dir)
+        {
+            var root = Path.TrimEndingDirectorySeparator(Path.GetFullPath(dir)) + Path.DirectorySeparatorChar;
+
+            lock (_lock)
+            {
+                return _services.Values
+                    .Where(s => !string.IsNullOrEmpty(s.ExePath) &&
+                                Path.GetFullPath(s.ExePath).StartsWith(root, StringComparison.OrdinalIgnoreCase))
+                    .Select(CloneService)
+                    .ToList();
+            }
+        }
+
+        // Service.AutoStart is set for every wrapped service, so the SCM start type is read from the registry.
+        // Delayed auto-start services are included; GetDelayedAutoStartServices returns only those.
+        public List<Service> GetAutoStartServices()
+        {
+            return FilterByStartType(_ => true);
+        }
+
+        public List<Service> GetDelayedAutoStartServices()
+        {
+            return FilterByStartType(delayed => delayed);
+        }
+
+        private List<Service> FilterByStartType(Func<bool, bool> matchDelayed)
+        {
+            List<Service> services;
+            lock (_lock)
+            {
+                services = _services.Values.Select(CloneService).ToList();
+            }
+
+            return services.Where(s =>
+                {
+                    using var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{s.Id}");
+                    if (serviceKey?.GetValue("Start") is not int start || start != 2) return false;
+                    return matchDelayed(serviceKey.GetValue("DelayedAutostart") is int d && d == 1);
+                })
+                .OrderBy(s => s.Name, StringComparer.CurrentCultureIgnoreCase)
+                .ToList();
+        }
+
+        // SCM logs 7024 (terminated with error), 7031 and 7034 (terminated unexpectedly) to the System log
+        private static readonly uint[] StartupFailureEventIds = { 7024, 7031, 7034 };
+
+        public async Task<List<StartupError>> GetServiceStartupErrorsAsync(string serviceId, DateTime since)
+        {
+            var service = GetTrackedService(serviceId);
+
+            return await Task.Run(() =>
+            {
+                var entries = EventLogHelper.ReadEntries("System", since, e =>
+                    e.Source == "Service Control Manager" &&
+                    StartupFailureEventIds.Contains(EventLogHelper.GetEventId(e)) &&
+                    EventLogHelper.ReferencesService(e, service.Name, service.Id));
+
+                return entries.Select(e =>
+                {
+                    var eventId = EventLogHelper.GetEventId(e);
+                    int exitCode = 0;
+                    // 7024 carries the service-specific exit code as its second insertion string, e.g. "%%1064"
+                    if (eventId == 7024 && e.ReplacementStrings.Length > 1)
+                        int.TryParse(e.ReplacementStrings[1].TrimStart('%'), out exitCode);
+
+                    return new StartupError
+                    {
+                        Timestamp = e.TimeGenerated,
+                        EventId = eventId,
+                        Message = e.Message,
+                        ExitCode = exitCode
+                    };
+                }).ToList();
+            });
+        }
+
+        // Newest first: entries the service logged under its own source plus SCM entries that name it
+        public async Task<List<ServiceEventLogEntry>> GetServiceEventLogAsync(string serviceId, int maxEntries = 100)
+        {
+            var service = GetTrackedService(serviceId);
+
+            return await Task.Run(() =>
+            {
+                var entries = EventLogHelper.ReadEntries("System", DateTime.MinValue, e =>
+                    string.Equals(e.Source, service.Id, StringComparison.OrdinalIgnoreCase) ||
+                    (e.Source == "Service Control Manager" && EventLogHelper.ReferencesService(e, service.Name, service.Id)),
+                    maxEntries);
+
+                return entries.Select(e => new ServiceEventLogEntry
+                {
+                    TimeGenerated = e.TimeGenerated,
+                    EventId = EventLogHelper.GetEventId(e),
+                    Level = e.EntryType.ToString(),
+                    Source = e.Source,
+                    Message = e.Message
+                }).ToList();
+            });
+        }
+
+        // The event log API can only clear whole logs, so this only works when the service
+        // registered its own source in a dedicated log rather than System or Application
+        public void ClearServiceEventLog(string serviceId)
+        {
+            GetTrackedService(serviceId);
+
+            if (!EventLog.SourceExists(serviceId))
+                throw new Exception($"No event log source is registered for service {serviceId}");
+
+            var logName = EventLog.LogNameFromSourceName(serviceId, ".");
+            if (SharedEventLogs.Contains(logName, StringComparer.OrdinalIgnoreCase))
+                throw new InvalidOperationException($"Service {serviceId} logs to the shared {logName} log, which cannot be cleared per service");
+
+            using var log = new EventLog(logName);
+            log.Clear();
+        }
+
+        private static readonly string[] SharedEventLogs = { "System", "Application", "Security", "Setup" };
+
+        public uint GetServiceHandleCount(string serviceId)
+        {
+            var service = GetTrackedService(serviceId);
+            int pid = ResolveTargetPid(service);
+            if (pid == 0) throw new Exception("Service is not running");
+            return ProcessUtils.GetHandleCount(pid);
+        }
+
+        public void SetHandleLeakThreshold(string serviceId, uint threshold)
+        {
+            var service = GetTrackedService(serviceId);
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                paramsKey.SetValue("HandleThreshold", unchecked((int)threshold), RegistryValueKind.DWord);
+            }
+            service.HandleThreshold = threshold;
+            if (threshold == 0) service.HandleLeakWarning = false;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        public void SetServiceAffinityMask(string serviceId, ulong affinityMask)
+        {
+            var service = GetTrackedService(serviceId);
+            if (Environment.ProcessorCount < 64 && (affinityMask >> Environment.ProcessorCount) != 0)
+                throw new ArgumentException($"Affinity mask references CPUs beyond the {Environment.ProcessorCount} available");
+
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                if (affinityMask == 0) paramsKey.DeleteValue("AffinityMask", false);
+                else paramsKey.SetValue("AffinityMask", unchecked((long)affinityMask), RegistryValueKind.QWord);
+            }
+            service.AffinityMask = affinityMask;
+
+            int pid = ResolveTargetPid(service);
+            if (pid != 0 && affinityMask != 0)
+            {
+                using var process = Process.GetProcessById(pid);
+                process.ProcessorAffinity = (IntPtr)unchecked((long)affinityMask);
+            }
+        }
+
+        public ulong GetServiceAffinityMask(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            return paramsKey.GetValue("AffinityMask") is long mask ? unchecked((ulong)mask) : 0;
+        }
+
+        // Enforced through the wrapper's job object, so the limit applies from the next service start
+        public void SetServiceMemoryLimitMB(string serviceId, ulong limitMB)
+        {
+            var service = GetTrackedService(serviceId);
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                if (limitMB == 0) paramsKey.DeleteValue("MemoryLimitMB", false);
+                else paramsKey.SetValue("MemoryLimitMB", unchecked((long)limitMB), RegistryValueKind.QWord);
+            }
+            service.MemoryLimitMB = limitMB;
+        }
+
+        public ulong GetServiceMemoryLimit(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            return paramsKey.GetValue("MemoryLimitMB") is long limit ? unchecked((ulong)limit) : 0;
+        }
+
+        public void SetServiceSchedule(string serviceId, ServiceSchedule schedule)
+        {
+            GetTrackedService(serviceId);
+
+            if (!string.IsNullOrWhiteSpace(schedule.StartCron)) CronExpression.Parse(schedule.StartCron);
+            if (!string.IsNullOrWhiteSpace(schedule.StopCron)) CronExpression.Parse(schedule.StopCron);
+
+            using var paramsKey = OpenParametersKey(serviceId, true);
+            paramsKey.SetValue("Schedule", JsonSerializer.Serialize(schedule), RegistryValueKind.String);
+        }
+
+        public ServiceSchedule? GetServiceSchedule(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            return ReadSchedule(serviceId);
+        }
+
+        private static ServiceSchedule? ReadSchedule(string serviceId)
+        {
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            var json = paramsKey.GetValue("Schedule") as string;
+            return string.IsNullOrEmpty(json) ? null : JsonSerializer.Deserialize<ServiceSchedule>(json);
+        }
+
+        private async Task RunSchedulesAsync()
+        {
+            var now = DateTime.Now;
+            var minute = now.AddTicks(-(now.Ticks % TimeSpan.TicksPerMinute));
+            if (minute == _lastScheduleMinute) return;
+            _lastScheduleMinute = minute;
+
+            List<Service> services;
+            lock (_lock)
+            {
+                services = _services.Values.ToList();
+            }
+
+            foreach (var service in services)
+            {
+                try
+                {
+                    var schedule = ReadSchedule(service.Id);
+                    if (schedule == null || !schedule.Enabled) continue;
+
+                    if (!string.IsNullOrWhiteSpace(schedule.StopCron) && service.Status == "运行中" &&
+                        CronExpression.Parse(schedule.StopCron).Matches(minute))
+                    {
+                        await StopServiceAsync(service.Id);
+                    }
+                    else if (!string.IsNullOrWhiteSpace(schedule.StartCron) && service.Status == "已停止" &&
+                             CronExpression.Parse(schedule.StartCron).Matches(minute))
+                    {
+                        await StartServiceAsync(service.Id);
+                    }
+                }
+                catch (Exception ex)
+                {
+                    System.Diagnostics.Debug.WriteLine($"Scheduled action failed for {service.Id}: {ex.Message}");
+                }
+            }
+        }
+
+        // Rules from the HNetCfg.FwPolicy2 COM API whose application path is the service executable.
+        // Enumerates every rule on the machine, so call it off the UI thread.
+        public List<FirewallRule> GetServiceFirewallRules(string serviceId)
+        {
+            var exePath = Path.GetFullPath(GetTrackedService(serviceId).ExePath);
+
+            var policyType = Type.GetTypeFromProgID("HNetCfg.FwPolicy2") ?? throw new Exception("Windows Firewall API is not available");
+            dynamic policy = Activator.CreateInstance(policyType)!;
+            dynamic? policyRules = null;
+
+            var rules = new List<FirewallRule>();
+            try
+            {
+                policyRules = policy.Rules;
+                foreach (dynamic rule in policyRules)
+                {
+                    try
+                    {
+                        string? application = rule.ApplicationName;
+                        if (string.IsNullOrEmpty(application) ||
+                            !string.Equals(Environment.ExpandEnvironmentVariables(application), exePath, StringComparison.OrdinalIgnoreCase))
+                            continue;
+
+                        int protocol = rule.Protocol;
+                        rules.Add(new FirewallRule
+                        {
+                            Name = rule.Name ?? "",
+                            Direction = (int)rule.Direction == 1 ? "inbound" : "outbound",
+                            Protocol = protocol switch { 6 => "TCP", 17 => "UDP", 1 => "ICMPv4", 58 => "ICMPv6", 256 => "Any", _ => protocol.ToString() },
+                            LocalPorts = rule.LocalPorts ?? "*",
+                            Action = (int)rule.Action == 1 ? "allow" : "block"
+                        });
+                    }
+                    finally
+                    {
+                        Marshal.FinalReleaseComObject(rule);
+                    }
+                }
+            }
+            finally
+            {
+                if (policyRules != null) Marshal.FinalReleaseComObject(policyRules);
+                Marshal.FinalReleaseComObject(policy);
+            }
+            return rules;
+        }
+
+        private const int MaxDiskUsageFiles = 10000;
+
+        public async Task<ServiceDiskUsage> GetServiceDiskUsageAsync(string serviceId)
+        {
+            var service = CloneService(GetTrackedService(serviceId));
+
+            return await Task.Run(() =>
+            {
+                var usage = new ServiceDiskUsage { ServiceId = serviceId };
+
+                var logDir = Path.Combine(Environment.GetFolderPath(Environment.SpecialFolder.CommonApplicationData), "windows_service_logs");
+                if (Directory.Exists(logDir))
+                {
+                    usage.LogFileSizeBytes = Directory.EnumerateFiles(logDir, $"{serviceId}_*.log").Sum(f => new FileInfo(f).Length);
+                }
+
+                // Capped so huge data directories don't stall the call
+                var workingDir = string.IsNullOrEmpty(service.WorkingDir) ? Path.GetDirectoryName(service.ExePath) : service.WorkingDir;
+                if (!string.IsNullOrEmpty(workingDir) && Directory.Exists(workingDir))
+                {
+                    var options = new EnumerationOptions { RecurseSubdirectories = true, IgnoreInaccessible = true };
+                    usage.WorkingDirSizeBytes = new DirectoryInfo(workingDir).EnumerateFiles("*", options).Take(MaxDiskUsageFiles).Sum(f => f.Length);
+                }
+
+                using var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}");
+                if (serviceKey != null) usage.RegistryKeySizeBytes = GetRegistryKeySize(serviceKey);
+
+                return usage;
+            });
+        }
+
+        // Approximate size of names and data of all values, recursing into subkeys
+        private static long GetRegistryKeySize(RegistryKey key)
+        {
+            long size = 0;
+            foreach (var name in key.GetValueNames())
+            {
+                size += name.Length * 2;
+                size += key.GetValue(name, null, RegistryValueOptions.DoNotExpandEnvironmentNames) switch
+                {
+                    string s => (s.Length + 1) * 2,
+                    string[] arr => arr.Sum(s => (s.Length + 1) * 2) + 2,
+                    byte[] bytes => bytes.Length,
+                    int => 4,
+                    long => 8,
+                    _ => 0
+                };
+            }
+
+            foreach (var subKeyName in key.GetSubKeyNames())
+            {
+                using var subKey = key.OpenSubKey(subKeyName);
+                if (subKey != null) size += GetRegistryKeySize(subKey);
+            }
+            return size;
+        }
+
+        // The wrapper only confines the process in a job when a memory limit is configured
+        public JobObjectInfo GetServiceJobObjectInfo(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using (var paramsKey = OpenParametersKey(serviceId, false))
+            {
+                if (paramsKey.GetValue("MemoryLimitMB") is not long limitMB || limitMB <= 0)
+                    throw new Exception("Job object confinement is not enabled for this service");
+            }
+            return JobObject.Query(JobObject.GetJobName(serviceId));
+        }
+
+        private static readonly Regex EnvReferenceRegex = new(@"%([^%=]+)%", RegexOptions.Compiled);
+
+        // Per-service overrides: the SCM-applied Environment value of the service key (NAME=value lines)
+        // and the wrapper-applied Parameters\Environment, which wins on conflicts
+        public Dictionary<string, string> GetServiceEnvironment(string serviceId)
+        {
+            var service = GetTrackedService(serviceId);
+
+            using var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}");
+            var result = ServiceUtils.ParseEnvironmentLines(serviceKey?.GetValue("Environment") as string[]);
+            foreach (var (name, value) in service.Environment ?? new Dictionary<string, string>())
+            {
+                result[name] = value;
+            }
+            return result;
+        }
+
+        // Replaces the wrapper-applied variables; an empty map clears them. Takes effect on the next start of the target process
+        public void SetServiceEnvironment(string serviceId, Dictionary<string, string> environment)
+        {
+            var service = GetTrackedService(serviceId);
+            if (environment.Keys.Any(name => string.IsNullOrEmpty(name) || name.IndexOf('=', 1) >= 0))
+                throw new ArgumentException("Environment variable names must be non-empty and cannot contain '='");
+
+            if (environment.Count == 0)
+            {
+                ClearServiceEnvironment(serviceId);
+                return;
+            }
+
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                paramsKey.SetValue("Environment", ServiceUtils.FormatEnvironmentLines(environment), RegistryValueKind.MultiString);
+            }
+            service.Environment = new Dictionary<string, string>(environment, StringComparer.OrdinalIgnoreCase);
+            service.UpdatedAt = DateTime.Now;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        // Merges into the existing variables; takes effect on the next start of the target process
+        public void SetServiceEnvironmentFromFile(string serviceId, string filePath)
+        {
+            var service = GetTrackedService(serviceId);
+            if (!File.Exists(filePath)) throw new FileNotFoundException(".env file not found", filePath);
+
+            var environment = new Dictionary<string, string>(service.Environment ?? new Dictionary<string, string>(), StringComparer.OrdinalIgnoreCase);
+            foreach (var (name, value) in EnvironmentManager.ParseDotEnv(File.ReadAllLines(filePath)))
+            {
+                environment[name] = value;
+            }
+
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                paramsKey.SetValue("Environment", ServiceUtils.FormatEnvironmentLines(environment), RegistryValueKind.MultiString);
+            }
+            service.Environment = environment;
+            service.UpdatedAt = DateTime.Now;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        public void ClearServiceEnvironment(string serviceId)
+        {
+            var service = GetTrackedService(serviceId);
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                paramsKey.DeleteValue("Environment", false);
+            }
+            service.Environment = null;
+            service.UpdatedAt = DateTime.Now;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        // System, then user, then service overrides; references resolve against the merged set first
+        public Dictionary<string, string> GetServiceEnvironmentExpanded(string serviceId)
+        {
+            var merged = GetInheritedEnvironmentRaw();
+            foreach (var (name, value) in GetServiceEnvironment(serviceId))
+            {
+                merged[name] = value;
+            }
+            return ExpandEnvironment(merged);
+        }
+
+        // What the wrapper starts with: system variables overlaid with user variables, Path concatenated
+        public Dictionary<string, string> GetServiceInheritedEnvironment()
+        {
+            return ExpandEnvironment(GetInheritedEnvironmentRaw());
+        }
+
+        public EnvironmentInheritanceReport GetEnvironmentInheritanceReport(string serviceId)
+        {
+            var inherited = GetServiceInheritedEnvironment();
+            var own = GetServiceEnvironment(serviceId);
+            var effective = GetServiceEnvironmentExpanded(serviceId);
+
+            var report = new EnvironmentInheritanceReport { ServiceId = serviceId };
+            foreach (var (name, value) in effective.OrderBy(kv => kv.Key, StringComparer.OrdinalIgnoreCase))
+            {
+                inherited.TryGetValue(name, out var inheritedValue);
+                report.Variables.Add(new EnvironmentInheritanceEntry
+                {
+                    Name = name,
+                    Value = value,
+                    InheritedValue = inheritedValue,
+                    Source = !own.ContainsKey(name) ? "inherited-only"
+                        : inheritedValue != null ? "override"
+                        : "added-by-service"
+                });
+            }
+            return report;
+        }
+
+        private static Dictionary<string, string> GetInheritedEnvironmentRaw()
+        {
+            var envManager = new EnvironmentManager();
+            var merged = new Dictionary<string, string>(envManager.ListSystemEnvironmentVariables(), StringComparer.OrdinalIgnoreCase);
+            foreach (var (name, value) in envManager.ListUserEnvironmentVariables())
+            {
+                if (string.Equals(name, "Path", StringComparison.OrdinalIgnoreCase) && merged.TryGetValue(name, out var systemPath))
+                    merged[name] = systemPath.TrimEnd(';') + ";" + value;
+                else
+                    merged[name] = value;
+            }
+            return merged;
+        }
+
+        private static Dictionary<string, string> ExpandEnvironment(Dictionary<string, string> merged)
+        {
+            var expanded = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+            foreach (var (name, value) in merged)
+            {
+                var resolved = EnvReferenceRegex.Replace(value, m =>
+                    merged.TryGetValue(m.Groups[1].Value, out var reference) && !reference.Contains('%')
+                        ? reference
+                        : m.Value);
+                expanded[name] = Environment.ExpandEnvironmentVariables(resolved);
+            }
+            return expanded;
+        }
+
+        // What the running process actually sees, which can differ from the registry after later edits
+        public Dictionary<string, string> GetProcessEnvironment(string serviceId)
+        {
+            int pid = ResolveTargetPid(GetTrackedService(serviceId));
+            if (pid == 0) throw new Exception("Service is not running");
+            return ProcessUtils.ReadProcessEnvironment(pid);
+        }
+
+        // The wrapper starts its child without a console and redirects stdout/stderr into the service log,
+        // so the console output of the window is whatever the log gained during it
+        public async Task<string> CaptureConsoleOutputAsync(string serviceId, int durationSeconds)
+        {
+            if (durationSeconds < 0) throw new ArgumentOutOfRangeException(nameof(durationSeconds));
+
+            var service = GetTrackedService(serviceId);
+            if (ResolveTargetPid(service) == 0) throw new Exception("Service is not running");
+
+            return await new LogManager().CaptureServiceLogAsync(serviceId, TimeSpan.FromSeconds(durationSeconds));
+        }
+
+        private const int MaxMonitorDurationSeconds = 300;
+
+        public async Task<List<RegistryActivityEvent>> MonitorServiceRegistryActivityAsync(string serviceId, int durationSeconds)
+        {
+            int pid = ResolveMonitorTarget(serviceId, durationSeconds);
+
+            return await Task.Run(() => EtwTrace.Collect(EtwTrace.KernelRegistryProvider, ulong.MaxValue, pid, TimeSpan.FromSeconds(durationSeconds), e =>
+            {
+                // Kernel-Registry event IDs: 1 CreateKey, 2 OpenKey, 3 DeleteKey, 5 SetValueKey, 6 DeleteValueKey
+                switch (e.EventId)
+                {
+                    case 1:
+                    case 2:
+                        var baseName = e.GetString("BaseName");
+                        var relativeName = e.GetString("RelativeName");
+                        return new RegistryActivityEvent
+                        {
+                            Timestamp = e.Timestamp,
+                            Operation = e.EventId == 1 ? "create" : "open",
+                            KeyPath = string.IsNullOrEmpty(baseName) ? relativeName : $@"{baseName}\{relativeName}"
+                        };
+                    case 3:
+                    case 5:
+                    case 6:
+                        return new RegistryActivityEvent
+                        {
+                            Timestamp = e.Timestamp,
+                            Operation = e.EventId == 5 ? "set" : "delete",
+                            KeyPath = e.GetString("KeyName"),
+                            ValueName = e.EventId == 3 ? "" : e.GetString("ValueName")
+                        };
+                    default:
+                        return null;
+                }
+            }));
+        }
+
+        // KERNEL_FILE_KEYWORD_FILENAME | CREATE | READ | WRITE | DELETE_PATH | RENAME_SETLINK_PATH | CREATE_NEW_FILE
+        private const ulong KernelFileKeywords = 0x1F90;
+
+        // Reads and writes only carry the file object, so names come from create events seen during the session;
+        // I/O on files opened before monitoring started has an empty path
+        public async Task<List<FileActivityEvent>> MonitorServiceFileActivityAsync(string serviceId, int durationSeconds)
+        {
+            int pid = ResolveMonitorTarget(serviceId, durationSeconds);
+            var fileNames = new Dictionary<ulong, string>();
+
+            return await Task.Run(() => EtwTrace.Collect(EtwTrace.KernelFileProvider, KernelFileKeywords, pid, TimeSpan.FromSeconds(durationSeconds), e =>
+            {
+                // Kernel-File event IDs: 12 Create, 15 Read, 16 Write, 26 DeletePath, 27 RenamePath, 30 CreateNewFile
+                switch (e.EventId)
+                {
+                    case 12:
+                    case 30:
+                        var fileName = e.GetString("FileName");
+                        fileNames[e.GetUInt64("FileObject")] = fileName;
+                        return new FileActivityEvent { Timestamp = e.Timestamp, Operation = "create", FilePath = fileName };
+                    case 15:
+                    case 16:
+                        return new FileActivityEvent
+                        {
+                            Timestamp = e.Timestamp,
+                            Operation = e.EventId == 15 ? "read" : "write",
+                            FilePath = fileNames.TryGetValue(e.GetUInt64("FileObject"), out var name) ? name : "",
+                            Bytes = e.GetUInt64("IOSize")
+                        };
+                    case 26:
+                    case 27:
+                        return new FileActivityEvent
+                        {
+                            Timestamp = e.Timestamp,
+                            Operation = e.EventId == 26 ? "delete" : "rename",
+                            FilePath = e.GetString("FilePath")
+                        };
+                    default:
+                        return null;
+                }
+            }));
+        }
+
+        private int ResolveMonitorTarget(string serviceId, int durationSeconds)
+        {
+            if (durationSeconds <= 0 || durationSeconds > MaxMonitorDurationSeconds)
+                throw new ArgumentOutOfRangeException(nameof(durationSeconds), $"Duration must be between 1 and {MaxMonitorDurationSeconds} seconds");
+
+            int pid = ResolveTargetPid(GetTrackedService(serviceId));
+            if (pid == 0) throw new Exception("Service is not running");
+            return pid;
+        }
+
+        public async Task<PageFaultSample> GetServicePageFaultRateAsync(string serviceId)
+        {
+            int pid = ResolveTargetPid(GetTrackedService(serviceId));
+            if (pid == 0) throw new Exception("Service is not running");
+
+            uint first = ProcessUtils.GetPageFaultCount(pid);
+            await Task.Delay(TimeSpan.FromSeconds(1));
+            uint second = ProcessUtils.GetPageFaultCount(pid);
+
+            return new PageFaultSample
+            {
+                Timestamp = DateTime.Now,
+                PageFaultCount = second,
+                PageFaultRate = second - first
+            };
+        }
+
+        public async Task<List<ServiceMemorySnapshot>> GetTopServicesByMemoryAsync(int n)
+        {
+            List<Service> running;
+            lock (_lock)
+            {
+                running = _services.Values.Where(s => s.Pid != 0).Select(CloneService).ToList();
+            }
+
+            var snapshots = await Task.WhenAll(running.Select(service => Task.Run(() =>
+            {
+                try
+                {
+                    int pid = ResolveTargetPid(service);
+                    using var process = Process.GetProcessById(pid);
+                    return new ServiceMemorySnapshot
+                    {
+                        ServiceId = service.Id,
+                        ServiceName = service.Name,
+                        Pid = pid,
+                        WorkingSetMB = process.WorkingSet64 / 1024.0 / 1024.0
+                    };
+                }
+                catch (Exception ex)
+                {
+                    System.Diagnostics.Debug.WriteLine($"Failed to read memory of {service.Id}: {ex.Message}");
+                    return null;
+                }
+            })));
+
+            return snapshots.OfType<ServiceMemorySnapshot>().OrderByDescending(s => s.WorkingSetMB).Take(n).ToList();
+        }
+
+        private static readonly TimeSpan ReportCpuSampleInterval = TimeSpan.FromSeconds(1);
+        private static readonly JsonSerializerOptions ReportJsonOptions = new() { PropertyNamingPolicy = JsonNamingPolicy.SnakeCaseLower };
+
+        // Snake-case JSON array for external monitoring agents; per-service collection runs concurrently
+        public async Task<byte[]> GetManagedServicesReportAsync()
+        {
+            await RefreshServiceStatusesAsync();
+            List<Service> services;
+            lock (_lock)
+            {
+                services = _services.Values.Select(CloneService).ToList();
+            }
+
+            var logDir = Path.Combine(Environment.GetFolderPath(Environment.SpecialFolder.CommonApplicationData), "windows_service_logs");
+            var entries = await Task.WhenAll(services.Select(service => CollectReportEntryAsync(service, logDir)));
+            return JsonSerializer.SerializeToUtf8Bytes(entries, ReportJsonOptions);
+        }
+
+        private async Task<ServiceReportEntry> CollectReportEntryAsync(Service service, string logDir)
+        {
+            var entry = new ServiceReportEntry
+            {
+                Id = service.Id,
+                Name = service.Name,
+                Alias = service.Alias,
+                Status = service.Status,
+                RestartCount = ReadRestartCount(service.Id)
+            };
+
+            try
+            {
+                var lastFailure = GetServiceExitCodeHistory(service.Id).LastOrDefault(e => !e.ExitedNormally);
+                if (lastFailure != null) entry.LastError = $"Exit code {lastFailure.ExitCode} at {lastFailure.Timestamp:o}";
+
+                if (Directory.Exists(logDir))
+                    entry.LogSizeBytes = Directory.EnumerateFiles(logDir, $"{service.Id}_*.log").Sum(f => new FileInfo(f).Length);
+
+                int pid = ResolveTargetPid(service);
+                if (pid == 0) return entry;
+
+                using var process = Process.GetProcessById(pid);
+                entry.Pid = pid;
+                entry.UptimeSeconds = (long)(DateTime.Now - process.StartTime).TotalSeconds;
+                entry.MemoryMb = process.WorkingSet64 / 1024.0 / 1024.0;
+
+                var cpuBefore = process.TotalProcessorTime;
+                await Task.Delay(ReportCpuSampleInterval);
+                process.Refresh();
+                entry.CpuPercent = (process.TotalProcessorTime - cpuBefore).TotalMilliseconds
+                                   / ReportCpuSampleInterval.TotalMilliseconds / Environment.ProcessorCount * 100;
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to collect report for {service.Id}: {ex.Message}");
+            }
+
+            return entry;
+        }
+
+        // For TCP only the local side counts, so remote endpoints on the same port number are ignored.
+        // Covers IPv4 and IPv6; TIME_WAIT rows owned by PID 0 (System Idle) are skipped.
+        public List<PortOccupant> GetServicesOnPort(ushort port, string protocol)
+        {
+            IEnumerable<int> pids = protocol.ToLowerInvariant() switch
+            {
+                "tcp" => NetworkUtils.GetTcpConnections().Where(c => NetworkUtils.ToHostPort(c.dwLocalPort) == port).Select(c => (int)c.dwOwningPid)
+                    .Concat(NetworkUtils.GetTcp6Connections().Where(c => NetworkUtils.ToHostPort(c.dwLocalPort) == port).Select(c => (int)c.dwOwningPid)),
+                "udp" => NetworkUtils.GetUdpEndpoints().Where(u => NetworkUtils.ToHostPort(u.dwLocalPort) == port).Select(u => (int)u.dwOwningPid)
+                    .Concat(NetworkUtils.GetUdp6Endpoints().Where(u => NetworkUtils.ToHostPort(u.dwLocalPort) == port).Select(u => (int)u.dwOwningPid)),
+                _ => throw new ArgumentException($"Unknown protocol: {protocol}")
+            };
+            pids = pids.Where(pid => pid != 0);
+
+            var servicesByPid = ServiceUtils.GetServiceNamesByPid();
+            List<Service> managed;
+            lock (_lock)
+            {
+                managed = _services.Values.Select(CloneService).ToList();
+            }
+
+            var occupants = new List<PortOccupant>();
+            foreach (int pid in pids.Distinct())
+            {
+                var occupant = new PortOccupant { Pid = pid };
+                try
+                {
+                    using var process = Process.GetProcessById(pid);
+                    occupant.ProcessName = process.ProcessName;
+                }
+                catch (ArgumentException)
+                {
+                    // Exited since the table was read
+                }
+
+                // Wrapped processes are matched through the wrapper's target PID, not the SCM host PID
+                var owner = managed.FirstOrDefault(s => s.Pid != 0 && ResolveTargetPid(s) == pid);
+                if (owner != null)
+                {
+                    occupant.ServiceName = owner.Id;
+                    occupant.IsManaged = true;
+                }
+                else if (servicesByPid.TryGetValue(pid, out var names))
+                {
+                    occupant.ServiceName = string.Join(", ", names);
+                    occupant.IsManaged = names.Any(n => managed.Any(s => string.Equals(s.Id, n, StringComparison.OrdinalIgnoreCase)));
+                }
+                occupants.Add(occupant);
+            }
+            return occupants;
+        }
+
+        private const int CertificateExpiryWarningDays = 30;
+
+        public List<CertificateInfo> GetServiceCertificates(string serviceId)
+        {
+            GetTrackedService(serviceId);
+
+            // What the process can reach: the machine stores plus the run-as account's own stores
+            var sid = GetAccountSid(GetServiceAccount(serviceId));
+            var certificates = new List<CertificateInfo>();
+            foreach (var storeName in new[] { "MY", "ROOT" })
+            {
+                certificates.AddRange(CertificateUtils.GetLocalMachineCertificates(storeName));
+                certificates.AddRange(CertificateUtils.GetUserCertificates(sid, storeName));
+            }
+
+            // ROOT routinely keeps expired legacy roots around, so only personal certificates are reported
+            var personal = certificates.Where(c => c.Store.EndsWith(@"\MY", StringComparison.Ordinal)).ToList();
+            var expired = personal.Where(c => c.IsExpired).ToList();
+            if (expired.Count > 0)
+            {
+                ServiceWarning?.Invoke(this, new ServiceWarningEventArgs
+                {
+                    ServiceId = serviceId,
+                    Kind = "certificate-expired",
+                    Message = $"{expired.Count} certificate(s) have expired: " +
+                              string.Join(", ", expired.Select(c => $"{c.SubjectName} ({c.ValidTo:yyyy-MM-dd})"))
+                });
+            }
+
+            var expiring = personal.Where(c => !c.IsExpired && c.DaysUntilExpiry < CertificateExpiryWarningDays).ToList();
+            if (expiring.Count > 0)
+            {
+                ServiceWarning?.Invoke(this, new ServiceWarningEventArgs
+                {
+                    ServiceId = serviceId,
+                    Kind = "certificate-expiry-warning",
+                    Message = $"{expiring.Count} certificate(s) expire within {CertificateExpiryWarningDays} days: " +
+                              string.Join(", ", expiring.Select(c => $"{c.SubjectName} ({c.ValidTo:yyyy-MM-dd})"))
+                });
+            }
+            return certificates;
+        }
+
+        // Last-write time of the SCM key. Config changes that rewrite values of the key itself move it forward,
+        // so it is the creation time only for services that were not reconfigured since.
+        public DateTime GetServiceInstallDate(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}");
+            if (serviceKey == null) throw new Exception("Service not found in registry");
+            return ServiceUtils.GetKeyLastWriteTime(serviceKey);
+        }
+
+        private static DateTime? TryGetKeyLastWriteTime(RegistryKey key)
+        {
+            try
+            {
+                return ServiceUtils.GetKeyLastWriteTime(key);
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to read key timestamp: {ex.Message}");
+                return null;
+            }
+        }
+
+        public SessionInfo GetServiceOwnerSession(string serviceId)
+        {
+            int pid = ResolveTargetPid(GetTrackedService(serviceId));
+            if (pid == 0) throw new Exception("Service is not running");
+            return SessionUtils.GetProcessSession(pid);
+        }
+
+        // Re-verifies the file and refreshes the cached result
+        public TrustLevel GetServiceTrustLevel(string serviceId)
+        {
+            var service = GetTrackedService(serviceId);
+            if (!File.Exists(service.ExePath)) throw new FileNotFoundException("Executable not found", service.ExePath);
+
+            var trust = TrustUtils.GetFileTrustLevel(service.ExePath);
+            _trustCache[(service.ExePath, File.GetLastWriteTimeUtc(service.ExePath))] = trust;
+            service.Trust = trust;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+            return trust;
+        }
+
+        // WinVerifyTrust can take seconds per file, so loading reuses the result until the executable changes
+        private readonly ConcurrentDictionary<(string Path, DateTime LastWrite), TrustLevel> _trustCache = new();
+
+        private TrustLevel? TryGetTrustLevel(string exePath)
+        {
+            try
+            {
+                if (!File.Exists(exePath)) return null;
+                return _trustCache.GetOrAdd((exePath, File.GetLastWriteTimeUtc(exePath)), key => TrustUtils.GetFileTrustLevel(key.Path));
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to verify signature of {exePath}: {ex.Message}");
+                return null;
+            }
+        }
+
+        private static readonly Lazy<string> CurrentExecutablePath = new(() => Process.GetCurrentProcess().MainModule?.FileName ?? "");
+
+        // Services keep the wrapper path they were created with, so an app moved or updated since leaves them behind
+        public WrapperVersionInfo GetServiceWrapperVersion(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            return ReadWrapperVersion(serviceId);
+        }
+
+        private static WrapperVersionInfo ReadWrapperVersion(string serviceId)
+        {
+            using var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}");
+            if (serviceKey?.GetValue("ImagePath", "", RegistryValueOptions.DoNotExpandEnvironmentNames) is not string imagePath || imagePath.Length == 0)
+                throw new Exception("Service not found in registry");
+
+            var (wrapperPath, _) = SplitCommandLine(Environment.ExpandEnvironmentVariables(imagePath));
+            var currentPath = CurrentExecutablePath.Value;
+            var info = new WrapperVersionInfo
+            {
+                WrapperPath = wrapperPath,
+                WrapperVersion = GetFileVersion(wrapperPath),
+                CurrentVersion = GetFileVersion(currentPath)
+            };
+            info.NeedsUpdate = info.WrapperVersion != info.CurrentVersion;
+            info.IsPathMismatch = !string.Equals(Path.GetFullPath(wrapperPath), Path.GetFullPath(currentPath), StringComparison.OrdinalIgnoreCase);
+            return info;
+        }
+
+        // All services usually share one wrapper executable, so its version resource is read once per file version
+        private static readonly ConcurrentDictionary<(string Path, DateTime LastWrite), string> FileVersionCache = new();
+
+        private static string GetFileVersion(string path)
+        {
+            if (!File.Exists(path)) return "";
+            return FileVersionCache.GetOrAdd((path, File.GetLastWriteTimeUtc(path)), key => FileVersionInfo.GetVersionInfo(key.Path).FileVersion ?? "");
+        }
+
+        // Used while loading, before the service is tracked
+        private static WrapperVersionInfo? TryGetWrapperVersion(string serviceId)
+        {
+            try
+            {
+                return ReadWrapperVersion(serviceId);
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to read wrapper version of {serviceId}: {ex.Message}");
+                return null;
+            }
+        }
+
+        // TCP over IPv4 only, see NetworkUtils.GetProcessTcpBytes
+        public NetworkBandwidthSample? GetServiceNetworkBandwidth(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            lock (_bandwidthHistory)
+            {
+                return _bandwidthHistory.TryGetValue(serviceId, out var samples) ? samples.LastOrDefault() : null;
+            }
+        }
+
+        public List<NetworkBandwidthSample> GetServiceNetworkBandwidthHistory(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            lock (_bandwidthHistory)
+            {
+                return _bandwidthHistory.TryGetValue(serviceId, out var samples) ? samples.ToList() : new List<NetworkBandwidthSample>();
+            }
+        }
+
+        private void SampleBandwidth()
+        {
+            List<Service> running;
+            lock (_lock)
+            {
+                running = _services.Values.Where(s => s.Pid != 0).ToList();
+            }
+
+            foreach (var service in running)
+            {
+                try
+                {
+                    int pid = ResolveTargetPid(service);
+                    if (pid == 0) continue;
+
+                    var (received, sent) = NetworkUtils.GetProcessTcpBytes(pid);
+                    var now = DateTime.Now;
+
+                    lock (_bandwidthHistory)
+                    {
+                        // Totals shrink when connections close, so negative deltas are clamped to zero
+                        if (_bandwidthTotals.TryGetValue(service.Id, out var previous) && previous.Pid == pid)
+                        {
+                            double seconds = (now - previous.Time).TotalSeconds;
+                            if (!_bandwidthHistory.TryGetValue(service.Id, out var samples))
+                            {
+                                samples = new List<NetworkBandwidthSample>();
+                                _bandwidthHistory[service.Id] = samples;
+                            }
+
+                            samples.Add(new NetworkBandwidthSample
+                            {
+                                ServiceId = service.Id,
+                                BytesReceivedPerSec = received > previous.Received ? (ulong)((received - previous.Received) / seconds) : 0,
+                                BytesSentPerSec = sent > previous.Sent ? (ulong)((sent - previous.Sent) / seconds) : 0,
+                                Timestamp = now
+                            });
+                            if (samples.Count > MaxBandwidthSamples) samples.RemoveAt(0);
+                        }
+                        _bandwidthTotals[service.Id] = (pid, received, sent, now);
+                    }
+                }
+                catch (Exception ex)
+                {
+                    System.Diagnostics.Debug.WriteLine($"Bandwidth sampling failed for {service.Id}: {ex.Message}");
+                }
+            }
+        }
+
+        private void SampleMetrics()
+        {
+            List<Service> running;
+            lock (_lock)
+            {
+                running = _services.Values.Where(s => s.Pid != 0).ToList();
+            }
+
+            // Each step is isolated so e.g. an access-denied handle query doesn't skip the performance sample
+            void Run(Service service, string step, Action<Service> action)
+            {
+                try
+                {
+                    action(service);
+                }
+                catch (Exception ex)
+                {
+                    System.Diagnostics.Debug.WriteLine($"{step} failed for {service.Id}: {ex.Message}");
+                }
+            }
+
+            foreach (var service in running)
+            {
+                Run(service, "Handle threshold check", CheckHandleThreshold);
+                Run(service, "Page fault check", CheckPageFaultRate);
+                Run(service, "Performance sampling", RecordPerformanceSample);
+                Run(service, "Daily start count refresh", RefreshDailyStartCount);
+            }
+        }
+
+        private readonly Dictionary<string, (int Pid, uint Count, DateTime Time)> _pageFaultCounts = new();
+        private readonly Dictionary<string, (IntPtr Token, IntPtr Profile)> _loadedProfiles = new();
+
+        // Rate over the metrics interval, compared against the per-second threshold
+        private void CheckPageFaultRate(Service service)
+        {
+            if (service.PageFaultThreshold == 0) return;
+
+            int pid = ResolveTargetPid(service);
+            if (pid == 0) return;
+
+            uint count = ProcessUtils.GetPageFaultCount(pid);
+            var now = DateTime.Now;
+
+            (int Pid, uint Count, DateTime Time) previous;
+            lock (_pageFaultCounts)
+            {
+                bool hasPrevious = _pageFaultCounts.TryGetValue(service.Id, out previous);
+                _pageFaultCounts[service.Id] = (pid, count, now);
+                if (!hasPrevious || previous.Pid != pid) return;
+            }
+
+            double rate = (count - previous.Count) / (now - previous.Time).TotalSeconds;
+            if (rate > service.PageFaultThreshold)
+            {
+                ServiceWarning?.Invoke(this, new ServiceWarningEventArgs
+                {
+                    ServiceId = service.Id,
+                    Kind = "memory-pressure-warning",
+                    Message = $"Page fault rate {rate:F0}/s exceeds threshold {service.PageFaultThreshold}/s"
+                });
+            }
+        }
+
+        // CPU usage is averaged over the whole metrics interval from the process's cumulative processor time,
+        // so short spikes between samples are not lost
+        private void RecordPerformanceSample(Service service)
+        {
+            int pid = ResolveTargetPid(service);
+            if (pid == 0) return;
+
+            using var process = Process.GetProcessById(pid);
+            var cpuTime = process.TotalProcessorTime;
+            var workingSetMB = process.WorkingSet64 / 1024.0 / 1024.0;
+            var now = DateTime.Now;
+
+            lock (_performanceHistory)
+            {
+                if (!_performanceHistory.TryGetValue(service.Id, out var history))
+                {
+                    history = new PerformanceHistory { ServiceId = service.Id };
+                    _performanceHistory[service.Id] = history;
+                }
+
+                history.Memory.Add(new MemorySample { Timestamp = now, WorkingSetMB = workingSetMB });
+                if (history.Memory.Count > MaxPerformanceSamples) history.Memory.RemoveAt(0);
+
+                if (_cpuTimes.TryGetValue(service.Id, out var previous) && previous.Pid == pid)
+                {
+                    double percent = (cpuTime - previous.CpuTime).TotalMilliseconds / (now - previous.Time).TotalMilliseconds
+                                     / Environment.ProcessorCount * 100;
+                    history.Cpu.Add(new CPUSample { Timestamp = now, CpuPercent = Math.Max(0, percent) });
+                    if (history.Cpu.Count > MaxPerformanceSamples) history.Cpu.RemoveAt(0);
+                }
+                _cpuTimes[service.Id] = (pid, cpuTime, now);
+            }
+        }
+
+        public List<CPUSample> GetServiceCPUHistory(string serviceId)
+        {
+            return GetServiceCPUAndMemoryHistory(serviceId).Cpu;
+        }
+
+        public PerformanceHistory GetServiceCPUAndMemoryHistory(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            lock (_performanceHistory)
+            {
+                if (!_performanceHistory.TryGetValue(serviceId, out var history)) return new PerformanceHistory { ServiceId = serviceId };
+                return new PerformanceHistory
+                {
+                    ServiceId = serviceId,
+                    Cpu = history.Cpu.ToList(),
+                    Memory = history.Memory.ToList()
+                };
+            }
+        }
+
+        // Only starts and stops issued through this manager that reached the target state are measured
+        public TimingDiagnostics GetServiceTimingDiagnostics(string serviceId)
+        {
+            return CloneService(GetTrackedService(serviceId)).Timing ?? new TimingDiagnostics();
+        }
+
+        private void RecordStartLatency(Service service, long elapsedMs)
+        {
+            lock (_startLatencies)
+            {
+                if (!_startLatencies.TryGetValue(service.Id, out var samples))
+                {
+                    samples = new List<long>();
+                    _startLatencies[service.Id] = samples;
+                }
+                samples.Add(elapsedMs);
+                if (samples.Count > MaxStartLatencySamples) samples.RemoveAt(0);
+
+                service.Timing ??= new TimingDiagnostics();
+                service.Timing.StartLatencyMs = elapsedMs;
+                service.Timing.AvgStartLatencyMs = (long)samples.Average();
+                service.Timing.MaxStartLatencyMs = samples.Max();
+                service.Timing.StartSamples = samples.Count;
+            }
+        }
+
+        // Service ID -> number of times the wrapper started the process today
+        public Dictionary<string, int> GetDailyStats()
+        {
+            List<Service> services;
+            lock (_lock)
+            {
+                services = _services.Values.ToList();
+            }
+
+            var stats = new Dictionary<string, int>(StringComparer.OrdinalIgnoreCase);
+            foreach (var service in services)
+            {
+                RefreshDailyStartCount(service);
+                stats[service.Id] = service.DailyStartCount;
+            }
+            return stats;
+        }
+
+        private void RefreshDailyStartCount(Service service)
+        {
+            using var paramsKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{service.Id}\Parameters");
+            if (paramsKey == null) return;
+
+            var (count, date) = ServiceUtils.ReadDailyStartCount(paramsKey);
+            if (count == service.DailyStartCount && date == service.DailyStartCountDate) return;
+
+            bool wasLooping = service.IsCrashLooping;
+            service.DailyStartCount = count;
+            service.DailyStartCountDate = date;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+            if (service.IsCrashLooping && !wasLooping)
+            {
+                ServiceWarning?.Invoke(this, new ServiceWarningEventArgs
+                {
+                    ServiceId = service.Id,
+                    Kind = "crash-loop-warning",
+                    Message = $"Service started {count} times today"
+                });
+            }
+        }
+
+        private void CheckHandleThreshold(Service service)
+        {
+            if (service.HandleThreshold == 0) return;
+
+            int pid = ResolveTargetPid(service);
+            if (pid == 0) return;
+
+            uint count = ProcessUtils.GetHandleCount(pid);
+            bool exceeded = count > service.HandleThreshold;
+            if (exceeded == service.HandleLeakWarning) return;
+
+            service.HandleLeakWarning = exceeded;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+            if (exceeded)
+            {
+                ServiceWarning?.Invoke(this, new ServiceWarningEventArgs
+                {
+                    ServiceId = service.Id,
+                    Kind = "handle-leak-warning",
+                    Message = $"Handle count {count} exceeds threshold {service.HandleThreshold}"
+                });
+            }
+        }
+
+        // The SCM PID belongs to the wrapper; the wrapper records the PID of the process it launched
+        private int ResolveTargetPid(Service service)
+        {
+            if (service.Pid == 0) return 0;
+
+            try
+            {
+                using var paramsKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{service.Id}\Parameters");
+                if (paramsKey?.GetValue("TargetPid") is int targetPid && targetPid != 0)
+                {
+                    using var process = Process.GetProcessById(targetPid);
+                    return targetPid;
+                }
+            }
+            catch (ArgumentException)
+            {
+                // Target process has exited
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to resolve target PID for {service.Id}: {ex.Message}");
+            }
+
+            return service.Pid;
+        }
+
+        private static readonly string[] RestartStatValues = { "RestartCount", "LastRestartTime", "LastRestartReason", "LastRestartInitiator", "TotalCrashes", "FirstCrashTime", "LastCrashTime" };
+
+        private const string TemplatesKey = @"SOFTWARE\WindowsServiceManager\Templates";
+
+        public void SaveServiceConfigTemplate(ServiceConfigTemplate template)
+        {
+            if (string.IsNullOrWhiteSpace(template.Name)) throw new ArgumentException("Template name is required");
+
+            using var key = Registry.LocalMachine.CreateSubKey(TemplatesKey);
+            key.SetValue(template.Name, JsonSerializer.Serialize(template), RegistryValueKind.String);
+        }
+
+        public List<ServiceConfigTemplate> ListServiceConfigTemplates()
+        {
+            using var key = Registry.LocalMachine.OpenSubKey(TemplatesKey);
+            if (key == null) return new List<ServiceConfigTemplate>();
+
+            return key.GetValueNames()
+                .Select(name => key.GetValue(name) as string)
+                .Where(json => !string.IsNullOrEmpty(json))
+                .Select(json => JsonSerializer.Deserialize<ServiceConfigTemplate>(json!))
+                .OfType<ServiceConfigTemplate>()
+                .ToList();
+        }
+
+        // Service specific fields (name, executable, working directory) are left untouched
+        public async Task ApplyServiceConfigTemplateAsync(string serviceId, string templateName)
+        {
+            var service = GetTrackedService(serviceId);
+            var template = ListServiceConfigTemplates().FirstOrDefault(t => string.Equals(t.Name, templateName, StringComparison.OrdinalIgnoreCase))
+                ?? throw new Exception($"Template {templateName} not found");
+
+            bool wasRunning = service.Status == "运行中";
+            if (wasRunning)
+            {
+                RecordRestartReason(serviceId, $"Config template {template.Name} applied", "user");
+                await StopServiceAsync(serviceId);
+            }
+
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                if (template.Args != null) paramsKey.SetValue("Args", template.Args, RegistryValueKind.String);
+                var policy = ServiceUtils.ReadRestartPolicy(paramsKey);
+                policy.MaxAttempts = template.MaxRestarts;
+                policy.InitialDelay = TimeSpan.FromSeconds(template.RestartDelaySeconds);
+                // A template delay above the stored cap would otherwise fail validation
+                if (policy.MaxDelay < policy.InitialDelay) policy.MaxDelay = policy.InitialDelay;
+                ValidateRestartPolicy(policy);
+                ServiceUtils.WriteRestartPolicy(paramsKey, policy);
+                paramsKey.SetValue("LogMaxSizeMB", template.LogMaxSizeMB, RegistryValueKind.DWord);
+                paramsKey.SetValue("StopGracePeriodSeconds", template.StopGracePeriodSeconds, RegistryValueKind.DWord);
+            }
+            if (template.Args != null) service.Args = template.Args;
+            service.UpdatedAt = DateTime.Now;
+
+            if (wasRunning) await StartServiceAsync(serviceId);
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        private const string WERKey = @"SOFTWARE\Microsoft\Windows\Windows Error Reporting";
+        // Private memory, handles and thread info without the full image pages
+        private const int HeapDumpFlags = 0x1A25;
+
+        // Dumps are written for the wrapped executable, which is the process that actually crashes
+        public WERPolicy GetServiceWERPolicy(string serviceId)
+        {
+            var exeName = Path.GetFileName(GetTrackedService(serviceId).ExePath);
+            var policy = new WERPolicy();
+
+            using var werKey = Registry.LocalMachine.OpenSubKey(WERKey);
+            policy.DontShowUI = werKey?.GetValue("DontShowUI") is int dontShowUI && dontShowUI != 0;
+            bool werDisabled = werKey?.GetValue("Disabled") is int disabled && disabled != 0;
+
+            using var dumpKey = werKey?.OpenSubKey($@"LocalDumps\{exeName}");
+            if (dumpKey == null) return policy;
+
+            policy.Enabled = !werDisabled;
+            policy.DumpFolder = dumpKey.GetValue("DumpFolder", "", RegistryValueOptions.DoNotExpandEnvironmentNames) as string ?? "";
+            if (dumpKey.GetValue("DumpCount") is int count) policy.MaxDumps = count;
+            policy.DumpType = dumpKey.GetValue("DumpType") switch
+            {
+                2 => "full",
+                0 => "heap",
+                _ => "mini"
+            };
+            return policy;
+        }
+
+        // Disabling removes the per-executable LocalDumps key. policy.DontShowUI is ignored, since it is
+        // machine-wide; use SetWERDontShowUI for that.
+        public void SetServiceWERPolicy(string serviceId, WERPolicy policy)
+        {
+            var exeName = Path.GetFileName(GetTrackedService(serviceId).ExePath);
+            int dumpType = policy.DumpType switch
+            {
+                "mini" => 1,
+                "heap" => 0,
+                "full" => 2,
+                _ => throw new ArgumentException($"Unknown dump type: {policy.DumpType}")
+            };
+            if (policy.MaxDumps < 0) throw new ArgumentException("MaxDumps must not be negative");
+
+            using var werKey = Registry.LocalMachine.CreateSubKey(WERKey, true);
+            if (!policy.Enabled)
+            {
+                werKey.DeleteSubKeyTree($@"LocalDumps\{exeName}", false);
+                return;
+            }
+
+            using var dumpKey = werKey.CreateSubKey($@"LocalDumps\{exeName}", true);
+            if (string.IsNullOrEmpty(policy.DumpFolder))
+                dumpKey.DeleteValue("DumpFolder", false);
+            else
+                dumpKey.SetValue("DumpFolder", policy.DumpFolder, RegistryValueKind.ExpandString);
+            dumpKey.SetValue("DumpCount", policy.MaxDumps, RegistryValueKind.DWord);
+            dumpKey.SetValue("DumpType", dumpType, RegistryValueKind.DWord);
+            if (dumpType == 0)
+                dumpKey.SetValue("CustomDumpFlags", HeapDumpFlags, RegistryValueKind.DWord);
+            else
+                dumpKey.DeleteValue("CustomDumpFlags", false);
+        }
+
+        // Suppresses the crash dialog for every process on the machine, not just managed services
+        public void SetWERDontShowUI(bool dontShowUI)
+        {
+            using var werKey = Registry.LocalMachine.CreateSubKey(WERKey, true);
+            werKey.SetValue("DontShowUI", dontShowUI ? 1 : 0, RegistryValueKind.DWord);
+        }
+
+        public ServiceResourceLimits GetServiceResourceLimits(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            var limits = new ServiceResourceLimits
+            {
+                MemoryLimitMB = paramsKey.GetValue("MemoryLimitMB") is long ml ? unchecked((ulong)ml) : 0,
+                AffinityMask = paramsKey.GetValue("AffinityMask") is long am ? unchecked((ulong)am) : 0,
+                HandleThreshold = paramsKey.GetValue("HandleThreshold") is int ht ? unchecked((uint)ht) : 0,
+                PageFaultThreshold = paramsKey.GetValue("PageFaultThreshold") is int pft ? unchecked((uint)pft) : 0
+            };
+
+            var policy = ServiceUtils.ReadRestartPolicy(paramsKey);
+            limits.MaxRestarts = policy.MaxAttempts;
+            limits.RestartCooldownSeconds = (int)policy.ResetAfter.TotalSeconds;
+            if (paramsKey.GetValue("StopGracePeriodSeconds") is int grace) limits.StopGracePeriodSeconds = grace;
+            if (paramsKey.GetValue("LogMaxSizeMB") is int logMax) limits.LogMaxSizeMB = logMax;
+
+            return limits;
+        }
+
+        // Restart, stop and log limits are read by the wrapper on its next start
+        public void SetServiceResourceLimits(string serviceId, ServiceResourceLimits limits)
+        {
+            if (limits.MaxRestarts < 0 || limits.RestartCooldownSeconds < 0 || limits.StopGracePeriodSeconds < 0 || limits.LogMaxSizeMB < 0)
+                throw new ArgumentException("Resource limits must not be negative");
+
+            SetServiceMemoryLimitMB(serviceId, limits.MemoryLimitMB);
+            SetServiceAffinityMask(serviceId, limits.AffinityMask);
+            SetHandleLeakThreshold(serviceId, limits.HandleThreshold);
+            GetTrackedService(serviceId).PageFaultThreshold = limits.PageFaultThreshold;
+
+            using var paramsKey = OpenParametersKey(serviceId, true);
+            paramsKey.SetValue("PageFaultThreshold", unchecked((int)limits.PageFaultThreshold), RegistryValueKind.DWord);
+            var policy = ServiceUtils.ReadRestartPolicy(paramsKey);
+            policy.MaxAttempts = limits.MaxRestarts;
+            policy.ResetAfter = TimeSpan.FromSeconds(limits.RestartCooldownSeconds);
+            ServiceUtils.WriteRestartPolicy(paramsKey, policy);
+            paramsKey.SetValue("StopGracePeriodSeconds", limits.StopGracePeriodSeconds, RegistryValueKind.DWord);
+            paramsKey.SetValue("LogMaxSizeMB", limits.LogMaxSizeMB, RegistryValueKind.DWord);
+        }
+
+        // Sends stdout/stderr to a fixed file instead of the shared log directory; null or empty reverts.
+        // Read by the wrapper on its next start.
+        public void SetServiceLogFile(string serviceId, string? path)
+        {
+            GetTrackedService(serviceId);
+            if (!string.IsNullOrEmpty(path)) ValidateLogFilePath(path);
+
+            using var paramsKey = OpenParametersKey(serviceId, true);
+            if (string.IsNullOrEmpty(path)) paramsKey.DeleteValue("LogFile", false);
+            else paramsKey.SetValue("LogFile", path, RegistryValueKind.String);
+        }
+
+        // The wrapper runs as a service, so relative paths would resolve against System32
+        private static void ValidateLogFilePath(string path)
+        {
+            if (!Path.IsPathFullyQualified(path))
+                throw new ArgumentException($"Log file path must be absolute: {path}");
+            if (path.IndexOfAny(Path.GetInvalidPathChars()) >= 0)
+                throw new ArgumentException($"Log file path contains invalid characters: {path}");
+        }
+
+        public WrapperConfig GetServiceWrapperConfig(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            return new WrapperConfig
+            {
+                AutoRestart = paramsKey.GetValue("AutoRestart") is int autoRestart && autoRestart == 1,
+                RestartPolicy = ServiceUtils.ReadRestartPolicy(paramsKey),
+                StopGracePeriodSeconds = paramsKey.GetValue("StopGracePeriodSeconds") is int grace ? grace : 5,
+                LogMaxSizeMB = paramsKey.GetValue("LogMaxSizeMB") is int logMax ? logMax : 0,
+                LogMaxFiles = paramsKey.GetValue("LogMaxFiles") is int logFiles && logFiles > 0 ? logFiles : 1,
+                LogFile = paramsKey.GetValue("LogFile") as string,
+                AffinityMask = paramsKey.GetValue("AffinityMask") is long mask ? unchecked((ulong)mask) : 0,
+                ProcessPriority = paramsKey.GetValue("ProcessPriority") as string,
+                HealthCheck = ServiceUtils.ReadHealthCheck(paramsKey),
+                Environment = paramsKey.GetValue("Environment") is string[] envLines ? ServiceUtils.ParseEnvironmentLines(envLines) : null
+            };
+        }
+
+        // Validates everything first, then writes in one registry transaction so the wrapper never starts
+        // with a half-applied configuration. Takes effect on the next service start.
+        public void SetServiceWrapperConfig(string serviceId, WrapperConfig config)
+        {
+            var service = GetTrackedService(serviceId);
+
+            var policy = config.RestartPolicy;
+            ValidateRestartPolicy(policy);
+            if (config.StopGracePeriodSeconds < 0 || config.LogMaxSizeMB < 0)
+                throw new ArgumentException("Stop grace period and log size must not be negative");
+            if (config.LogMaxFiles < 1) throw new ArgumentException("At least one rotated log file must be kept");
+            if (!string.IsNullOrEmpty(config.LogFile)) ValidateLogFilePath(config.LogFile);
+            if (Environment.ProcessorCount < 64 && (config.AffinityMask >> Environment.ProcessorCount) != 0)
+                throw new ArgumentException($"Affinity mask references CPUs beyond the {Environment.ProcessorCount} available");
+            if (config.ProcessPriority != null && !Enum.TryParse<ProcessPriorityClass>(config.ProcessPriority, true, out _))
+                throw new ArgumentException($"Unknown process priority: {config.ProcessPriority}");
+            if (config.HealthCheck != null) ValidateHealthCheck(config.HealthCheck);
+
+            using (var transaction = new RegistryTransaction($"Wrapper config for {serviceId}"))
+            {
+                using (var paramsKey = transaction.OpenLocalMachineKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}\Parameters", true))
+                {
+                    paramsKey.SetValue("AutoRestart", config.AutoRestart ? 1 : 0, RegistryValueKind.DWord);
+                    ServiceUtils.WriteRestartPolicy(paramsKey, policy);
+                    paramsKey.SetValue("StopGracePeriodSeconds", config.StopGracePeriodSeconds, RegistryValueKind.DWord);
+                    paramsKey.SetValue("LogMaxSizeMB", config.LogMaxSizeMB, RegistryValueKind.DWord);
+                    paramsKey.SetValue("LogMaxFiles", config.LogMaxFiles, RegistryValueKind.DWord);
+
+                    if (string.IsNullOrEmpty(config.LogFile)) paramsKey.DeleteValue("LogFile", false);
+                    else paramsKey.SetValue("LogFile", config.LogFile, RegistryValueKind.String);
+
+                    if (config.AffinityMask == 0) paramsKey.DeleteValue("AffinityMask", false);
+                    else paramsKey.SetValue("AffinityMask", unchecked((long)config.AffinityMask), RegistryValueKind.QWord);
+
+                    if (config.ProcessPriority == null) paramsKey.DeleteValue("ProcessPriority", false);
+                    else paramsKey.SetValue("ProcessPriority", config.ProcessPriority, RegistryValueKind.String);
+
+                    ServiceUtils.WriteHealthCheck(paramsKey, config.HealthCheck);
+
+                    if (config.Environment == null || config.Environment.Count == 0) paramsKey.DeleteValue("Environment", false);
+                    else paramsKey.SetValue("Environment", ServiceUtils.FormatEnvironmentLines(config.Environment), RegistryValueKind.MultiString);
+                }
+                transaction.Commit();
+            }
+
+            service.AutoRestart = config.AutoRestart;
+            service.AffinityMask = config.AffinityMask;
+            service.Environment = config.Environment == null || config.Environment.Count == 0
+                ? null
+                : new Dictionary<string, string>(config.Environment, StringComparer.OrdinalIgnoreCase);
+            service.UpdatedAt = DateTime.Now;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        public RestartPolicy GetServiceRestartPolicy(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            return ServiceUtils.ReadRestartPolicy(paramsKey);
+        }
+
+        // Read by the wrapper on its next start
+        public void SetServiceRestartPolicy(string serviceId, RestartPolicy policy)
+        {
+            ValidateRestartPolicy(policy);
+
+            var service = GetTrackedService(serviceId);
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                ServiceUtils.WriteRestartPolicy(paramsKey, policy);
+            }
+            service.UpdatedAt = DateTime.Now;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        public HealthCheckConfig? GetServiceHealthCheck(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            return ServiceUtils.ReadHealthCheck(paramsKey);
+        }
+
+        // Passing null removes the health check. Read by the wrapper on its next start.
+        public void SetServiceHealthCheck(string serviceId, HealthCheckConfig? config)
+        {
+            if (config != null) ValidateHealthCheck(config);
+
+            var service = GetTrackedService(serviceId);
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                ServiceUtils.WriteHealthCheck(paramsKey, config);
+            }
+            service.UpdatedAt = DateTime.Now;
+            ServiceUpdated?.Invoke(this, CloneService(service));
+        }
+
+        private static void ValidateRestartPolicy(RestartPolicy policy)
+        {
+            if (policy.MaxAttempts < 0 || policy.InitialDelay < TimeSpan.Zero || policy.ResetAfter < TimeSpan.Zero)
+                throw new ArgumentException("Restart policy values must not be negative");
+            if (policy.BackoffFactor < 1) throw new ArgumentException("Backoff factor must be at least 1");
+            if (policy.MaxDelay < policy.InitialDelay) throw new ArgumentException("Maximum delay must not be shorter than the initial delay");
+        }
+
+        private static void ValidateHealthCheck(HealthCheckConfig config)
+        {
+            if (config.Type is not ("http" or "tcp" or "exec")) throw new ArgumentException($"Unknown health check type: {config.Type}");
+            if (string.IsNullOrWhiteSpace(config.Target)) throw new ArgumentException("Health check target is required");
+            if (config.Type == "http" && (!Uri.TryCreate(config.Target, UriKind.Absolute, out var uri) || (uri.Scheme != Uri.UriSchemeHttp && uri.Scheme != Uri.UriSchemeHttps)))
+                throw new ArgumentException($"Invalid health check URL: {config.Target}");
+            if (config.Type == "tcp" && !HealthCheckHelper.TryParseHostPort(config.Target, out _, out _))
+                throw new ArgumentException($"Invalid health check endpoint, expected host:port: {config.Target}");
+            if (config.IntervalSeconds < 1 || config.TimeoutSeconds < 1 || config.UnhealthyThreshold < 1)
+                throw new ArgumentException("Health check interval, timeout and threshold must be positive");
+            if (config.TimeoutSeconds > config.IntervalSeconds)
+                throw new ArgumentException("Health check timeout must not exceed the interval");
+        }
+
+        public List<ExitCodeEntry> GetServiceExitCodeHistory(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            return ServiceUtils.ParseExitCodeHistory(paramsKey.GetValue("ExitCodeHistory") as string);
+        }
+
+        public List<PidHistoryEntry> GetServicePidHistory(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            return ServiceUtils.ParsePidHistory(paramsKey.GetValue("PidHistory") as string[]);
+        }
+
+        public async Task<List<RestartStats>> GetServiceRestartStatsAsync()
+        {
+            List<string> serviceIds;
+            lock (_lock)
+            {
+                serviceIds = _services.Keys.ToList();
+            }
+
+            return await Task.Run(() =>
+            {
+                var stats = new List<RestartStats>();
+                foreach (var serviceId in serviceIds)
+                {
+                    try
+                    {
+                        using var paramsKey = OpenParametersKey(serviceId, false);
+                        var stat = new RestartStats
+                        {
+                            ServiceId = serviceId,
+                            RestartCount = paramsKey.GetValue("RestartCount") is int rc ? rc : 0,
+                            TotalCrashes = paramsKey.GetValue("TotalCrashes") is int tc ? tc : 0
+                        };
+
+                        if (DateTime.TryParse(paramsKey.GetValue("LastRestartTime") as string, out var lastRestart))
+                            stat.LastRestartTime = lastRestart;
+
+                        if (stat.TotalCrashes > 1 &&
+                            DateTime.TryParse(paramsKey.GetValue("FirstCrashTime") as string, out var firstCrash) &&
+                            DateTime.TryParse(paramsKey.GetValue("LastCrashTime") as string, out var lastCrash))
+                        {
+                            stat.MeanTimeBetweenCrashes = (lastCrash - firstCrash) / (stat.TotalCrashes - 1);
+                        }
+
+                        stats.Add(stat);
+                    }
+                    catch (Exception ex)
+                    {
+                        System.Diagnostics.Debug.WriteLine($"Failed to read restart stats for {serviceId}: {ex.Message}");
+                    }
+                }
+
+                // Most unstable services first
+                return stats.OrderByDescending(s => s.RestartCount).ThenByDescending(s => s.TotalCrashes).ToList();
+            });
+        }
+
+        public void ResetServiceRestartCount(string serviceId)
+        {
+            var service = GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, true);
+            foreach (var name in RestartStatValues)
+            {
+                paramsKey.DeleteValue(name, false);
+            }
+            service.LastRestartReason = null;
+            service.LastRestartInitiator = null;
+        }
+
+        // Restarts done by the wrapper record their own reason; this reads the latest one
+        public (string? Reason, string? Initiator) GetServiceLastRestartReason(string serviceId)
+        {
+            var service = GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            service.LastRestartReason = paramsKey.GetValue("LastRestartReason") as string;
+            service.LastRestartInitiator = paramsKey.GetValue("LastRestartInitiator") as string;
+            return (service.LastRestartReason, service.LastRestartInitiator);
+        }
+
+        private void RecordRestartReason(string serviceId, string reason, string initiator)
+        {
+            var service = GetTrackedService(serviceId);
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                paramsKey.SetValue("LastRestartReason", reason, RegistryValueKind.String);
+                paramsKey.SetValue("LastRestartInitiator", initiator, RegistryValueKind.String);
+            }
+            service.LastRestartReason = reason;
+            service.LastRestartInitiator = initiator;
+        }
+
+        public async Task ResetAllRestartStatsAsync()
+        {
+            List<string> serviceIds;
+            lock (_lock)
+            {
+                serviceIds = _services.Keys.ToList();
+            }
+
+            await Task.Run(() =>
+            {
+                foreach (var serviceId in serviceIds)
+                {
+                    ResetServiceRestartCount(serviceId);
+                }
+            });
+        }
+
+        public List<DependencyValidation> ValidateDependencies(IEnumerable<string> dependencies)
+        {
+            using var session = OpenSCMSession();
+            return dependencies
+                .Where(d => !string.IsNullOrWhiteSpace(d))
+                .Select(d =>
+                {
+                    var validation = new DependencyValidation { ServiceName = d, Exists = session.ServiceExists(d) };
+                    validation.Status = validation.Exists ? session.QueryStatus(d).Status : "未安装";
+                    validation.IsAvailable = validation.Status == "运行中";
+                    return validation;
+                })
+                .ToList();
+        }
+
+        public DependencyStatus CheckServiceDependencyAvailability(string serviceId)
+        {
+            GetTrackedService(serviceId);
+
+            var status = new DependencyStatus();
+            using var session = OpenSCMSession();
+            foreach (var dependency in GetServiceDependencies(serviceId))
+            {
+                var (state, _) = session.QueryStatus(dependency);
+                status.Dependencies.Add(new DependencyInfo
+                {
+                    ServiceName = dependency,
+                    Status = state,
+                    IsAvailable = state == "运行中"
+                });
+            }
+            status.AllAvailable = status.Dependencies.All(d => d.IsAvailable);
+            return status;
+        }
+
+        public async Task StartServiceWithDependenciesAsync(string serviceId)
+        {
+            GetTrackedService(serviceId);
+
+            var dependencies = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
+            CollectDependencies(serviceId, dependencies);
+
+            var chain = GetServiceDependencyChain(dependencies);
+            await Task.Run(() =>
+            {
+                using var session = OpenSCMSession();
+                var results = session.StartServices(chain.Where(name => session.QueryStatus(name).Status != "运行中").ToList());
+                var failure = results.Values.FirstOrDefault(ex => ex != null);
+                if (failure != null) throw failure;
+            });
+
+            await StartServiceAsync(serviceId);
+        }
+
+        private void CollectDependencies(string serviceName, HashSet<string> collected)
+        {
+            foreach (var dependency in GetServiceDependencies(serviceName))
+            {
+                if (collected.Add(dependency)) CollectDependencies(dependency, collected);
+            }
+        }
+
+        public void SendCustomControlCode(string serviceId, int code, string description)
+        {
+            GetTrackedService(serviceId);
+            if (code < 128 || code > 255) throw new ArgumentOutOfRangeException(nameof(code), "Custom control codes must be in the range 128-255");
+
+            using var sc = new ServiceController(serviceId);
+            RunControl(() => sc.ExecuteCommand(code), $"Failed to send control code {code}");
+            AuditLog.Write("control", serviceId, $"{code} ({description})");
+        }
+
+        public Dictionary<int, string> GetServiceCustomControls(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var paramsKey = OpenParametersKey(serviceId, false);
+            var json = paramsKey.GetValue("CustomControls") as string;
+            return string.IsNullOrEmpty(json) ? new Dictionary<int, string>() : JsonSerializer.Deserialize<Dictionary<int, string>>(json) ?? new Dictionary<int, string>();
+        }
+
+        public void SetServiceCustomControls(string serviceId, Dictionary<int, string> controls)
+        {
+            GetTrackedService(serviceId);
+            if (controls.Keys.Any(code => code < 128 || code > 255))
+                throw new ArgumentOutOfRangeException(nameof(controls), "Custom control codes must be in the range 128-255");
+
+            using var paramsKey = OpenParametersKey(serviceId, true);
+            paramsKey.SetValue("CustomControls", JsonSerializer.Serialize(controls), RegistryValueKind.String);
+        }
+
+        public void SetValidateWorkingDirOnStart(bool enabled)
+        {
+            ValidateWorkingDirOnStart = enabled;
+        }
+
+        public const int DefaultBulkConcurrency = 5;
+
+        // Starts the services over one SCM connection, dependencies first and in parallel within each
+        // dependency level, and raises a single ServicesUpdated afterwards
+        public Task<Dictionary<string, Exception?>> BulkStartServicesAsync(IEnumerable<string> serviceIds, int maxConcurrency = DefaultBulkConcurrency)
+        {
+            return BulkControlAsync(serviceIds, maxConcurrency, false, (session, service) =>
+            {
+                RunPreStartChecks(service);
+                var stopwatch = Stopwatch.StartNew();
+                session.StartService(service.Id, GetStartTimeout(service));
+                RecordStartLatency(service, stopwatch.ElapsedMilliseconds);
+            });
+        }
+
+        // Dependents are stopped before their dependencies, which the SCM refuses to stop while they run
+        public Task<Dictionary<string, Exception?>> BulkStopServicesAsync(IEnumerable<string> serviceIds, int maxConcurrency = DefaultBulkConcurrency)
+        {
+            return BulkControlAsync(serviceIds, maxConcurrency, true, (session, service) => session.StopService(service.Id, GetStopTimeout(service)));
+        }
+
+        private async Task<Dictionary<string, Exception?>> BulkControlAsync(IEnumerable<string> serviceIds, int maxConcurrency, bool dependentsFirst, Action<SCMSession, Service> control)
+        {
+            if (maxConcurrency < 1)
+                throw new ArgumentOutOfRangeException(nameof(maxConcurrency), "Concurrency limit must be at least 1");
+
+            var results = new ConcurrentDictionary<string, Exception?>(StringComparer.OrdinalIgnoreCase);
+            var targets = new List<Service>();
+            lock (_lock)
+            {
+                foreach (var serviceId in serviceIds.Distinct(StringComparer.OrdinalIgnoreCase))
+                {
+                    if (_services.TryGetValue(serviceId, out var service))
+                        targets.Add(service);
+                    else
+                        results[serviceId] = new Exception("Service not found");
+                }
+            }
+
+            if (targets.Count == 0) return new Dictionary<string, Exception?>(results, StringComparer.OrdinalIgnoreCase);
+
+            List<List<Service>> levels;
+            try
+            {
+                levels = GroupByDependencyLevel(targets);
+            }
+            catch (CyclicDependencyException ex)
+            {
+                foreach (var service in targets) results[service.Id] = ex;
+                return new Dictionary<string, Exception?>(results, StringComparer.OrdinalIgnoreCase);
+            }
+            if (dependentsFirst) levels.Reverse();
+
+            var updated = await Task.Run(() =>
+            {
+                using var session = OpenSCMSession();
+                var options = new ParallelOptions { MaxDegreeOfParallelism = maxConcurrency };
+                foreach (var level in levels)
+                {
+                    Parallel.ForEach(level, options, service =>
+                    {
+                        try
+                        {
+                            control(session, service);
+                            results[service.Id] = null;
+                        }
+                        catch (Exception ex)
+                        {
+                            results[service.Id] = ex;
+                        }
+                    });
+                }
+
+                var changed = new List<Service>();
+                foreach (var service in targets)
+                {
+                    if (SetServiceStatus(service, session.QueryStatus(service.Id)))
+                        changed.Add(CloneService(service));
+                }
+                return changed;
+            });
+
+            if (updated.Count > 0)
+                ServicesUpdated?.Invoke(this, updated);
+
+            return new Dictionary<string, Exception?>(results, StringComparer.OrdinalIgnoreCase);
+        }
+
+        // Level 0 holds services with no dependency among the targets; every other service sits one level
+        // above the highest target it depends on, directly or through services outside the set
+        private List<List<Service>> GroupByDependencyLevel(List<Service> targets)
+        {
+            var byId = targets.ToDictionary(s => s.Id, StringComparer.OrdinalIgnoreCase);
+            var levels = new Dictionary<string, int>(StringComparer.OrdinalIgnoreCase);
+
+            // The chain lists dependencies before dependents, so their levels are known when a dependent is reached
+            var order = GetServiceDependencyChain(byId.Keys);
+            foreach (var id in order)
+            {
+                int level = 0;
+                foreach (var dependency in GetTargetDependencies(id, byId))
+                {
+                    level = Math.Max(level, levels[dependency] + 1);
+                }
+                levels[id] = level;
+            }
+
+            return order
+                .GroupBy(id => levels[id])
+                .OrderBy(g => g.Key)
+                .Select(g => g.Select(id => byId[id]).ToList())
+                .ToList();
+        }
+
+        private HashSet<string> GetTargetDependencies(string serviceId, Dictionary<string, Service> targets)
+        {
+            var found = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
+            var visited = new HashSet<string>(StringComparer.OrdinalIgnoreCase) { serviceId };
+            var pending = new Stack<string>();
+            pending.Push(serviceId);
+
+            while (pending.Count > 0)
+            {
+                List<string> dependencies;
+                try
+                {
+                    dependencies = GetServiceDependencies(pending.Pop());
+                }
+                catch (Exception)
+                {
+                    continue;
+                }
+
+                foreach (var dependency in dependencies)
+                {
+                    if (!visited.Add(dependency)) continue;
+                    if (targets.ContainsKey(dependency)) found.Add(dependency);
+                    else pending.Push(dependency);
+                }
+            }
+            return found;
+        }
+
+        // Shared by single and bulk start: a missing working directory fails the start, missing dependencies only warn
+        private void RunPreStartChecks(Service service)
+        {
+            if (ValidateWorkingDirOnStart)
+            {
+                // The wrapper falls back to the executable's directory when no working directory is set
+                var workingDir = string.IsNullOrEmpty(service.WorkingDir) ? Path.GetDirectoryName(service.ExePath) : service.WorkingDir;
+                if (!string.IsNullOrEmpty(workingDir) && !Directory.Exists(workingDir))
+                    throw new WorkingDirectoryNotFoundException(service.Id, workingDir);
+            }
+
+            try
+            {
+                var dependencyStatus = CheckServiceDependencyAvailability(service.Id);
+                if (!dependencyStatus.AllAvailable)
+                {
+                    var missing = dependencyStatus.Dependencies.Where(d => !d.IsAvailable).Select(d => d.ServiceName);
+                    ServiceWarning?.Invoke(this, new ServiceWarningEventArgs
+                    {
+                        ServiceId = service.Id,
+                        Kind = "dependency-warning",
+                        Message = $"Dependencies not running: {string.Join(", ", missing)}"
+                    });
+                }
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Dependency check failed for {service.Id}: {ex.Message}");
+            }
+        }
+
+        public async Task StartServiceAsync(string serviceId)
+        {
+            Service? service;
+            lock (_lock)
+            {
+                if (!_services.TryGetValue(serviceId, out service)) throw new Exception("Service not found");
+            }
+
+            RunPreStartChecks(service);
+
+            using var sc = new ServiceController(serviceId);
+            if (sc.Status != ServiceControllerStatus.Running)
+            {
+                var stopwatch = Stopwatch.StartNew();
+                RunControl(() => sc.Start(), "Failed to start service");
+                try
+                {
+                    sc.WaitForStatus(ServiceControllerStatus.Running, GetStartTimeout(service));
+                    RecordStartLatency(service, stopwatch.ElapsedMilliseconds);
+                }
+                catch (System.ServiceProcess.TimeoutException) { }
+            }
+            await UpdateServiceStatusAsync(service);
+            ServiceUpdated?.Invoke(this, service);
+        }
+
+        public async Task RestartServiceAsync(string serviceId, string reason, string initiator = "user")
+        {
+            RecordRestartReason(serviceId, reason, initiator);
+            await StopServiceAsync(serviceId);
+            await StartServiceAsync(serviceId);
+        }
+
+        public async Task PauseServiceAsync(string serviceId)
+        {
+            var service = GetTrackedService(serviceId);
+
+            using var sc = new ServiceController(serviceId);
+            if (!sc.CanPauseAndContinue) throw new InvalidOperationException($"Service {serviceId} does not accept pause and continue controls");
+            if (sc.Status == ServiceControllerStatus.Running)
+            {
+                RunControl(() => sc.Pause(), "Failed to pause service");
+                try
+                {
+                    sc.WaitForStatus(ServiceControllerStatus.Paused, GetStartTimeout(service));
+                }
+                catch (System.ServiceProcess.TimeoutException) { }
+            }
+            await UpdateServiceStatusAsync(service);
+            ServiceUpdated?.Invoke(this, service);
+        }
+
+        public async Task ResumeServiceAsync(string serviceId)
+        {
+            var service = GetTrackedService(serviceId);
+
+            using var sc = new ServiceController(serviceId);
+            if (!sc.CanPauseAndContinue) throw new InvalidOperationException($"Service {serviceId} does not accept pause and continue controls");
+            if (sc.Status == ServiceControllerStatus.Paused)
+            {
+                RunControl(() => sc.Continue(), "Failed to resume service");
+                try
+                {
+                    sc.WaitForStatus(ServiceControllerStatus.Running, GetStartTimeout(service));
+                }
+                catch (System.ServiceProcess.TimeoutException) { }
+            }
+            await UpdateServiceStatusAsync(service);
+            ServiceUpdated?.Invoke(this, service);
+        }
+
+        public async Task StopServiceAsync(string serviceId)
+        {
+            Service? service;
+            lock (_lock)
+            {
+                if (!_services.TryGetValue(serviceId, out service)) throw new Exception("Service not found");
+            }
+
+            using var sc = new ServiceController(serviceId);
+            // The SCM accepts a stop request while paused, so a paused service doesn't have to be resumed first
+            if (sc.Status is ServiceControllerStatus.Running or ServiceControllerStatus.Paused or ServiceControllerStatus.PausePending)
+            {
+                var stopwatch = Stopwatch.StartNew();
+                RunControl(() => sc.Stop(), "Failed to stop service");
+                try
+                {
+                    sc.WaitForStatus(ServiceControllerStatus.Stopped, GetStopTimeout(service));
+                    lock (_startLatencies)
+                    {
+                        service.Timing ??= new TimingDiagnostics();
+                        service.Timing.StopLatencyMs = stopwatch.ElapsedMilliseconds;
+                    }
+                }
+                catch (System.ServiceProcess.TimeoutException) { }
+            }
+            await UpdateServiceStatusAsync(service);
+            ServiceUpdated?.Invoke(this, service);
+        }
+
+        private static string BuildWrapperCommand(string serviceName)
+        {
+            var module = Process.GetCurrentProcess().MainModule;
+            if (module == null) throw new Exception("Cannot determine current executable path");
+
+            // Construct command safely
+            return $"\"{module.FileName}\" --service-wrapper \"{serviceName}\"";
+        }
+
+        // Changes the wrapped command in place so the service keeps its name and startup type;
+        // a running service is stopped for the update and started again when restartIfRunning is set
+        public async Task<Service> UpdateServiceAsync(string serviceId, ServiceConfig config, bool restartIfRunning = true)
+        {
+            var service = GetTrackedService(serviceId);
+
+            if (!File.Exists(config.ExePath))
+                throw new FileNotFoundException("Executable not found", config.ExePath);
+
+            var displayName = string.IsNullOrWhiteSpace(config.Name) ? service.Name : config.Name;
+            if (displayName.Any(c => !char.IsLetterOrDigit(c) && c != '_' && c != '-' && c != ' '))
+                throw new ArgumentException("Service Name contains invalid characters.");
+
+            // The cached status can be stale or 未知, so ask the SCM. A paused service is stopped like a running
+            // one and paused again after the restart; pending states are left alone.
+            ServiceControllerStatus liveStatus;
+            using (var sc = new ServiceController(serviceId))
+            {
+                liveStatus = sc.Status;
+            }
+            bool wasPaused = liveStatus == ServiceControllerStatus.Paused;
+            bool wasRunning = liveStatus is ServiceControllerStatus.Running or ServiceControllerStatus.Paused;
+            if (wasRunning)
+                await StopServiceAsync(serviceId);
+
+            bool renamed = displayName != service.Name;
+            string wrapperCmd = BuildWrapperCommand(serviceId);
+            WithServiceHandle(serviceId, ServiceUtils.SERVICE_CHANGE_CONFIG, serviceHandle =>
+            {
+                if (!ServiceUtils.ChangeServiceConfig(serviceHandle, ServiceUtils.SERVICE_NO_CHANGE, ServiceUtils.SERVICE_NO_CHANGE, ServiceUtils.SERVICE_NO_CHANGE,
+                        wrapperCmd, null, IntPtr.Zero, null, null, null, renamed ? displayName : null))
+                    throw ServiceOperationException.FromLastError("Failed to update service configuration");
+            });
+
+            var workingDir = string.IsNullOrEmpty(config.WorkingDir) ? Path.GetDirectoryName(config.ExePath) ?? "" : config.WorkingDir;
+            using (var paramsKey = OpenParametersKey(serviceId, true))
+            {
+                paramsKey.SetValue("ExePath", config.ExePath);
+                paramsKey.SetValue("Args", config.Args ?? "");
+                paramsKey.SetValue("WorkingDir", workingDir);
+                paramsKey.SetValue("DisplayName", displayName);
+                paramsKey.SetValue("AutoRestart", config.AutoRestart ? 1 : 0);
+                SetOptionalTimeout(paramsKey, "StartTimeoutMs", config.StartTimeout);
+                SetOptionalTimeout(paramsKey, "StopTimeoutMs", config.StopTimeout);
+            }
+
+            if (renamed)
+                await RunCommandAsync("sc.exe", $"description \"{serviceId}\" \"Managed by Windows Service Manager: {displayName}\"");
+
+            lock (_lock)
+            {
+                service.Name = displayName;
+                service.ExePath = config.ExePath;
+                service.Args = config.Args ?? "";
+                service.WorkingDir = workingDir;
+                service.AutoRestart = config.AutoRestart;
+                service.StartTimeout = config.StartTimeout;
+                service.StopTimeout = config.StopTimeout;
+                service.UpdatedAt = DateTime.Now;
+            }
+            ServiceUpdated?.Invoke(this, CloneService(service));
+
+            if (wasRunning && restartIfRunning)
+            {
+                RecordRestartReason(serviceId, "Configuration updated", "user");
+                await StartServiceAsync(serviceId);
+                if (wasPaused) await PauseServiceAsync(serviceId);
+            }
+
+            return CloneService(service);
+        }
+
+        private static void SetOptionalTimeout(RegistryKey paramsKey, string name, TimeSpan timeout)
+        {
+            if (timeout > TimeSpan.Zero)
+                paramsKey.SetValue(name, (int)timeout.TotalMilliseconds, RegistryValueKind.DWord);
+            else
+                paramsKey.DeleteValue(name, false);
+        }
+
+        public async Task DeleteServiceAsync(string serviceId)
+                {
+                    lock (_lock)
+                    {
+                        if (!_services.ContainsKey(serviceId)) throw new Exception("Service not found");
+
+                        // 清理 monitor
+                        if (_monitors.TryGetValue(serviceId, out var monitor))
+                        {
+                            monitor.Dispose();
+                            _monitors.Remove(serviceId);
+                        }
+                    }
+
+                    await StopServiceAsync(serviceId);
+
+                    // Use P/Invoke to delete service
+                    IntPtr scmHandle = ServiceUtils.OpenSCManager(null, null, ServiceUtils.SC_MANAGER_CONNECT);
+                    if (scmHandle == IntPtr.Zero)
+                        throw ServiceOperationException.FromLastError("Failed to open SC Manager");
+
+                    try
+                    {
+                        // We need DELETE access
+                        IntPtr serviceHandle = ServiceUtils.OpenService(scmHandle, serviceId, ServiceUtils.DELETE);
+                        if (serviceHandle == IntPtr.Zero)
+                            throw ServiceOperationException.FromLastError("Failed to open service for deletion");
+
+                        try
+                        {
+                            if (!ServiceUtils.DeleteService(serviceHandle))
+                                throw ServiceOperationException.FromLastError("Failed to delete service");
+                        }
+                        finally
+                        {
+                            ServiceUtils.CloseServiceHandle(serviceHandle);
+                        }
+                    }
+                    finally
+                    {
+                        ServiceUtils.CloseServiceHandle(scmHandle);
+                    }
+
+                    // Remove from managed services index
+                    RemoveFromManagedServicesIndex(serviceId);
+
+                    try { DeleteSecureCredential(GetRunAsCredentialTarget(serviceId)); } catch { }
+
+                    // Clean up registry Parameters subkey
+                    try
+                    {
+                        using var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}", writable: true);
+                        key?.DeleteSubKeyTree("Parameters", throwOnMissingSubKey: false);
+                    }
+                    catch (Exception ex)
+                    {
+                        System.Diagnostics.Debug.WriteLine($"Failed to delete Parameters registry key: {ex.Message}");
+                    }
+
+                    lock (_lock)
+                    {
+                        _services.Remove(serviceId);
+                    }
+                    ForgetServiceMetrics(serviceId);
+                }
+
+        // In-memory samples are keyed by service name, which a later service may reuse
+        private void ForgetServiceMetrics(string serviceId)
+        {
+            lock (_bandwidthHistory)
+            {
+                _bandwidthHistory.Remove(serviceId);
+                _bandwidthTotals.Remove(serviceId);
+            }
+            lock (_startLatencies)
+            {
+                _startLatencies.Remove(serviceId);
+            }
+            lock (_performanceHistory)
+            {
+                _performanceHistory.Remove(serviceId);
+                _cpuTimes.Remove(serviceId);
+            }
+        }
+
+
+        public void StoreSecureCredential(string targetName, string username, string password)
+        {
+            CredentialUtils.Write(targetName, username, password);
+        }
+
+        public (string Username, string Password) GetSecureCredential(string targetName)
+        {
+            return CredentialUtils.Read(targetName);
+        }
+
+        public void DeleteSecureCredential(string targetName)
+        {
+            CredentialUtils.Delete(targetName);
+        }
+
+        private static string GetRunAsCredentialTarget(string serviceId) => $"WindowsServiceManager/{serviceId}";
+
+        // The SCM keeps the password as an LSA secret; a copy goes to Credential Manager, never to the registry
+        public void SetServiceRunAs(string serviceId, string account, string? password)
+        {
+            GetTrackedService(serviceId);
+
+            WithServiceHandle(serviceId, ServiceUtils.SERVICE_CHANGE_CONFIG, serviceHandle =>
+            {
+                if (!ServiceUtils.ChangeServiceConfig(serviceHandle, ServiceUtils.SERVICE_NO_CHANGE, ServiceUtils.SERVICE_NO_CHANGE, ServiceUtils.SERVICE_NO_CHANGE,
+                        null, null, IntPtr.Zero, null, account, password ?? string.Empty, null))
+                    throw ServiceOperationException.FromLastError("Failed to change service account");
+            });
+
+            var target = GetRunAsCredentialTarget(serviceId);
+            if (!string.IsNullOrEmpty(password))
+            {
+                StoreSecureCredential(target, account, password);
+            }
+            else
+            {
+                try { DeleteSecureCredential(target); } catch { }
+            }
+
+            GrantParametersWriteAccess(serviceId, GetAccountSid(account));
+        }
+
+        // The wrapper reports back (PIDs, exit codes, DNS test results, ...) by writing to its Parameters key,
+        // which only administrators and LocalSystem may do by default
+        private static void GrantParametersWriteAccess(string serviceId, string sid)
+        {
+            if (sid == "S-1-5-18") return;
+
+            using var paramsKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}\Parameters",
+                RegistryKeyPermissionCheck.ReadWriteSubTree, RegistryRights.ReadPermissions | RegistryRights.ChangePermissions)
+                ?? throw new Exception("Service parameters not found in registry");
+
+            var security = paramsKey.GetAccessControl();
+            security.AddAccessRule(new RegistryAccessRule(new SecurityIdentifier(sid),
+                RegistryRights.QueryValues | RegistryRights.SetValue | RegistryRights.ReadKey,
+                InheritanceFlags.ContainerInherit, PropagationFlags.None, AccessControlType.Allow));
+            paramsKey.SetAccessControl(security);
+        }
+
+        public TimeSpan GetServicePreshutdownTimeout(string serviceId)
+        {
+            GetTrackedService(serviceId);
+
+            uint timeoutMs = 0;
+            WithServiceHandle(serviceId, ServiceUtils.SERVICE_QUERY_CONFIG, serviceHandle =>
+            {
+                IntPtr buffer = Marshal.AllocHGlobal(Marshal.SizeOf<ServiceUtils.SERVICE_PRESHUTDOWN_INFO>());
+                try
+                {
+                    if (!ServiceUtils.QueryServiceConfig2(serviceHandle, ServiceUtils.SERVICE_CONFIG_PRESHUTDOWN_INFO, buffer, Marshal.SizeOf<ServiceUtils.SERVICE_PRESHUTDOWN_INFO>(), out _))
+                        throw ServiceOperationException.FromLastError("Failed to query preshutdown timeout");
+                    timeoutMs = Marshal.PtrToStructure<ServiceUtils.SERVICE_PRESHUTDOWN_INFO>(buffer).dwPreshutdownTimeout;
+                }
+                finally
+                {
+                    Marshal.FreeHGlobal(buffer);
+                }
+            });
+            return TimeSpan.FromMilliseconds(timeoutMs);
+        }
+
+        private const int ERROR_ACCESS_DENIED = 5;
+
+        // Probes each access right separately, since OpenService fails as a whole when any requested right is denied
+        public ServiceControlPermissions CanControlService(string serviceName)
+        {
+            IntPtr scmHandle = ServiceUtils.OpenSCManager(null, null, ServiceUtils.SC_MANAGER_CONNECT);
+            if (scmHandle == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError("Failed to open SC Manager");
+
+            try
+            {
+                bool HasAccess(uint access)
+                {
+                    IntPtr serviceHandle = ServiceUtils.OpenService(scmHandle, serviceName, access);
+                    if (serviceHandle != IntPtr.Zero)
+                    {
+                        ServiceUtils.CloseServiceHandle(serviceHandle);
+                        return true;
+                    }
+                    if (Marshal.GetLastWin32Error() == ERROR_ACCESS_DENIED) return false;
+                    throw ServiceOperationException.FromLastError("Failed to open service");
+                }
+
+                return new ServiceControlPermissions
+                {
+                    CanStart = HasAccess(ServiceUtils.SERVICE_START),
+                    CanStop = HasAccess(ServiceUtils.SERVICE_STOP),
+                    CanDelete = HasAccess(ServiceUtils.DELETE),
+                    CanChangeConfig = HasAccess(ServiceUtils.SERVICE_CHANGE_CONFIG)
+                };
+            }
+            finally
+            {
+                ServiceUtils.CloseServiceHandle(scmHandle);
+            }
+        }
+
+        // Kept per service until it is removed or the list is hard-reloaded, so loading
+        // doesn't open four extra SCM handles per service every time
+        private readonly ConcurrentDictionary<string, ServiceControlPermissions> _permissionsCache = new(StringComparer.OrdinalIgnoreCase);
+
+        private ServiceControlPermissions? TryGetControlPermissions(string serviceName)
+        {
+            if (_permissionsCache.TryGetValue(serviceName, out var cached)) return cached;
+            try
+            {
+                var permissions = CanControlService(serviceName);
+                _permissionsCache[serviceName] = permissions;
+                return permissions;
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to query permissions for {serviceName}: {ex.Message}");
+                return null;
+            }
+        }
+
+        public ServiceRecoveryConfig GetServiceRecoveryActions(string serviceId)
+        {
+            GetTrackedService(serviceId);
+            using var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}");
+            if (serviceKey == null) throw new Exception("Service not found in registry");
+
+            var actions = ServiceUtils.ParseFailureActions(serviceKey.GetValue("FailureActions") as byte[], serviceKey.GetValue("FailureCommand") as string);
+            if (actions == null || actions.Actions.Count == 0)
+            {
+                return new ServiceRecoveryConfig
+                {
+                    FirstFailureAction = RecoveryActionType.None,
+                    SecondFailureAction = RecoveryActionType.None,
+                    SubsequentFailureAction = RecoveryActionType.None,
+                    ResetPeriodSeconds = actions?.ResetPeriodSeconds ?? 0,
+                    RestartDelayMilliseconds = 0,
+                    FailureCommand = actions?.Command
+                };
+            }
+
+            // The SCM repeats the last action for every further failure
+            RecoveryActionType ActionAt(int index) => actions.Actions[Math.Min(index, actions.Actions.Count - 1)].Type switch
+            {
+                "restart" => RecoveryActionType.Restart,
+                "reboot" => RecoveryActionType.Reboot,
+                "run-program" => RecoveryActionType.RunProgram,
+                _ => RecoveryActionType.None
+            };
+
+            return new ServiceRecoveryConfig
+            {
+                FirstFailureAction = ActionAt(0),
+                SecondFailureAction = ActionAt(1),
+                SubsequentFailureAction = ActionAt(2),
+                ResetPeriodSeconds = actions.ResetPeriodSeconds,
+                RestartDelayMilliseconds = (int)(actions.Actions.FirstOrDefault(a => a.Type != "none")?.Delay.TotalMilliseconds ?? 0),
+                FailureCommand = actions.Command
+            };
+        }
+
+        public void SetServiceRecoveryActions(string serviceId, ServiceRecoveryConfig config)
+        {
+            GetTrackedService(serviceId);
+            if (config.ResetPeriodSeconds < 0 || config.RestartDelayMilliseconds < 0)
+                throw new ArgumentException("Reset period and restart delay must not be negative");
+
+            var types = new[] { config.FirstFailureAction, config.SecondFailureAction, config.SubsequentFailureAction };
+            if (types.Contains(RecoveryActionType.RunProgram) && string.IsNullOrWhiteSpace(config.FailureCommand))
+                throw new ArgumentException("A failure command is required for run-program actions");
+
+            int size = Marshal.SizeOf<ServiceUtils.SC_ACTION>();
+            IntPtr actions = Marshal.AllocHGlobal(size * types.Length);
+            try
+            {
+                for (int i = 0; i < types.Length; i++)
+                {
+                    Marshal.StructureToPtr(new ServiceUtils.SC_ACTION
+                    {
+                        Type = (uint)types[i],
+                        Delay = types[i] == RecoveryActionType.None ? 0 : (uint)config.RestartDelayMilliseconds
+                    }, actions + i * size, false);
+                }
+
+                var info = new ServiceUtils.SERVICE_FAILURE_ACTIONS
+                {
+                    dwResetPeriod = (uint)config.ResetPeriodSeconds,
+                    // An empty string clears the command, null would leave it unchanged
+                    lpCommand = config.FailureCommand ?? "",
+                    cActions = (uint)types.Length,
+                    lpsaActions = actions
+                };
+
+                // Restart actions need SERVICE_START on the handle; reboot actions need SeShutdownPrivilege enabled
+                void Apply() => WithServiceHandle(serviceId, ServiceUtils.SERVICE_CHANGE_CONFIG | ServiceUtils.SERVICE_START, serviceHandle =>
+                {
+                    if (!ServiceUtils.ChangeServiceConfig2(serviceHandle, ServiceUtils.SERVICE_CONFIG_FAILURE_ACTIONS, ref info))
+                        throw ServiceOperationException.FromLastError("Failed to set recovery actions");
+                });
+
+                if (types.Contains(RecoveryActionType.Reboot)) TokenUtils.WithPrivilege(TokenUtils.SE_SHUTDOWN_NAME, Apply);
+                else Apply();
+            }
+            finally
+            {
+                Marshal.FreeHGlobal(actions);
+            }
+        }
+
+        public void SetServicePreshutdownTimeout(string serviceId, TimeSpan timeout)
+        {
+            GetTrackedService(serviceId);
+
+            WithServiceHandle(serviceId, ServiceUtils.SERVICE_CHANGE_CONFIG, serviceHandle =>
+            {
+                var info = new ServiceUtils.SERVICE_PRESHUTDOWN_INFO { dwPreshutdownTimeout = (uint)timeout.TotalMilliseconds };
+                if (!ServiceUtils.ChangeServiceConfig2(serviceHandle, ServiceUtils.SERVICE_CONFIG_PRESHUTDOWN_INFO, ref info))
+                    throw ServiceOperationException.FromLastError("Failed to set preshutdown timeout");
+            });
+        }
+
+        private static TimeSpan GetStartTimeout(Service service)
+        {
+            return service.StartTimeout > TimeSpan.Zero ? service.StartTimeout : SCMSession.DefaultStateTimeout;
+        }
+
+        // QueryServiceConfig2 reports the system default when nothing was configured, so the
+        // registry value decides whether the service declared its own timeout
+        private static TimeSpan GetStopTimeout(Service service)
+        {
+            if (service.StopTimeout > TimeSpan.Zero) return service.StopTimeout;
+
+            using var serviceKey = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{service.Id}");
+            return serviceKey?.GetValue("PreshutdownTimeout") is int ms && ms > 0 ? TimeSpan.FromMilliseconds(ms) : SCMSession.DefaultStateTimeout;
+        }
+
+        private static void WithServiceHandle(string serviceId, uint access, Action<IntPtr> action)
+        {
+            IntPtr scmHandle = ServiceUtils.OpenSCManager(null, null, ServiceUtils.SC_MANAGER_CONNECT);
+            if (scmHandle == IntPtr.Zero)
+                throw ServiceOperationException.FromLastError("Failed to open SC Manager");
+
+            try
+            {
+                IntPtr serviceHandle = ServiceUtils.OpenService(scmHandle, serviceId, access);
+                if (serviceHandle == IntPtr.Zero)
+                    throw ServiceOperationException.FromLastError("Failed to open service");
+
+                try
+                {
+                    action(serviceHandle);
+                }
+                finally
+                {
+                    ServiceUtils.CloseServiceHandle(serviceHandle);
+                }
+            }
+            finally
+            {
+                ServiceUtils.CloseServiceHandle(scmHandle);
+            }
+        }
+
+        public static string GetWindowsErrorMessage(uint code)
+        {
+            return ServiceOperationException.GetWindowsErrorMessage(unchecked((int)code));
+        }
+
+        // ServiceController reports SCM failures as InvalidOperationException wrapping the Win32 error
+        private static void RunControl(Action action, string operation)
+        {
+            try
+            {
+                action();
+            }
+            catch (InvalidOperationException ex) when (ex.InnerException is Win32Exception win32)
+            {
+                throw new ServiceOperationException(operation, win32.NativeErrorCode);
+            }
+        }
+
+        private Service GetTrackedService(string serviceId)
+        {
+            lock (_lock)
+            {
+                if (!_services.TryGetValue(serviceId, out var service)) throw new Exception("Service not found");
+                return service;
+            }
+        }
+
+        private static RegistryKey OpenParametersKey(string serviceId, bool writable)
+        {
+            var key = Registry.LocalMachine.OpenSubKey($@"SYSTEM\CurrentControlSet\Services\{serviceId}\Parameters", writable);
+            if (key == null) throw new Exception("Service configuration not found in registry");
+            return key;
+        }
+
+        private void AddToManagedServicesIndex(string serviceName)
+        {
+            try
+            {
+                using var hklm = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry64);
+                using var indexKey = hklm.CreateSubKey(@"SOFTWARE\WindowsServiceManager\ManagedServices");
+                using var serviceIndexKey = indexKey.CreateSubKey(serviceName);
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to add service to index: {ex.Message}");
+            }
+        }
+
+        private void ReconcileManagedServicesIndex()
+        {
+            try
+            {
+                using var hklm = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry64);
+                using var indexKey = hklm.OpenSubKey(@"SOFTWARE\WindowsServiceManager\ManagedServices");
+                using var servicesKey = hklm.OpenSubKey(@"SYSTEM\CurrentControlSet\Services");
+                if (indexKey == null || servicesKey == null) return;
+
+                foreach (var serviceName in indexKey.GetSubKeyNames())
+                {
+                    using var serviceKey = servicesKey.OpenSubKey(serviceName);
+                    if (serviceKey == null) RemoveFromManagedServicesIndex(serviceName);
+                }
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to reconcile service index: {ex.Message}");
+            }
+        }
+
+        private void RemoveFromManagedServicesIndex(string serviceName)
+        {
+            try
+            {
+                using var hklm = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry64);
+                using var indexKey = hklm.OpenSubKey(@"SOFTWARE\WindowsServiceManager\ManagedServices", true);
+                indexKey?.DeleteSubKey(serviceName, false);
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to remove service from index: {ex.Message}");
+            }
+        }
+
+        private async Task LoadServicesAsync()
+        {
+            var services = new Dictionary<string, Service>();
+            await Task.Run(() =>
+            {
+                try
+                {
+                    using var hklm = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry64);
+                    using var indexKey = hklm.OpenSubKey(@"SOFTWARE\WindowsServiceManager\ManagedServices");
                     
                     if (indexKey != null)
                     {
@@ -460,11 +4141,13 @@ namespace Services.Core.Services
                 }
             });
 
+            List<string> removedServiceIds;
             lock (_lock)
             {
-                var removedServiceIds = _services.Keys.Except(services.Keys).ToList();
+                removedServiceIds = _services.Keys.Except(services.Keys).ToList();
                 foreach (var serviceId in removedServiceIds)
                 {
+                    _permissionsCache.TryRemove(serviceId, out _);
                     if (_monitors.TryGetValue(serviceId, out var monitor))
                     {
                         monitor.Dispose();
@@ -474,6 +4157,11 @@ namespace Services.Core.Services
                 
                 _services = services;
             }
+
+            foreach (var serviceId in removedServiceIds)
+            {
+                ForgetServiceMetrics(serviceId);
+            }
         }
 
         private void LoadSingleService(RegistryKey servicesKey, string serviceName, Dictionary<string, Service> services)
@@ -491,15 +4179,26 @@ namespace Services.Core.Services
             var exePath = paramsKey.GetValue("ExePath") as string;
             if (string.IsNullOrEmpty(exePath)) return;
 
+            if (!(paramsKey.GetValue("ManagedID") is string managedId && Guid.TryParse(managedId, out _))) AssignManagedID(serviceKey, serviceName);
+
             var displayName = paramsKey.GetValue("DisplayName") as string ?? serviceName;
             var args = paramsKey.GetValue("Args") as string;
             var workingDir = paramsKey.GetValue("WorkingDir") as string;
             var autoRestartVal = paramsKey.GetValue("AutoRestart");
             bool autoRestart = (autoRestartVal is int val && val == 1);
 
+            uint handleThreshold = paramsKey.GetValue("HandleThreshold") is int ht ? unchecked((uint)ht) : 0;
+            uint pageFaultThreshold = paramsKey.GetValue("PageFaultThreshold") is int pft ? unchecked((uint)pft) : 0;
+            ulong affinityMask = paramsKey.GetValue("AffinityMask") is long am ? unchecked((ulong)am) : 0;
+            ulong memoryLimitMB = paramsKey.GetValue("MemoryLimitMB") is long ml ? unchecked((ulong)ml) : 0;
+            var pidHistory = ServiceUtils.ParsePidHistory(paramsKey.GetValue("PidHistory") as string[]);
+            var exitCodeHistory = ServiceUtils.ParseExitCodeHistory(paramsKey.GetValue("ExitCodeHistory") as string);
+            var (dailyStartCount, dailyStartDate) = ServiceUtils.ReadDailyStartCount(paramsKey);
+
             var createdAtStr = paramsKey.GetValue("CreatedAt") as string;
             DateTime createdAt = DateTime.Now;
             if (DateTime.TryParse(createdAtStr, out var dt)) createdAt = dt;
+            else createdAt = TryGetKeyLastWriteTime(serviceKey) ?? createdAt;
 
             var (status, pid) = ServiceUtils.GetServiceStatus(serviceName);
 
@@ -511,6 +4210,25 @@ namespace Services.Core.Services
                 Args = args,
                 WorkingDir = workingDir,
                 AutoRestart = autoRestart,
+                HandleThreshold = handleThreshold,
+                PageFaultThreshold = pageFaultThreshold,
+                AffinityMask = affinityMask,
+                MemoryLimitMB = memoryLimitMB,
+                IconPath = paramsKey.GetValue("IconPath") as string,
+                Alias = paramsKey.GetValue("CustomAlias") as string,
+                Permissions = TryGetControlPermissions(serviceName),
+                PidHistory = pidHistory,
+                ExitCodeHistory = exitCodeHistory,
+                DailyStartCount = dailyStartCount,
+                DailyStartCountDate = dailyStartDate,
+                LastRestartReason = paramsKey.GetValue("LastRestartReason") as string,
+                LastRestartInitiator = paramsKey.GetValue("LastRestartInitiator") as string,
+                DebugMode = paramsKey.GetValue("DebugMode") is int debug && debug == 1,
+                StartTimeout = paramsKey.GetValue("StartTimeoutMs") is int startMs ? TimeSpan.FromMilliseconds(startMs) : TimeSpan.Zero,
+                StopTimeout = paramsKey.GetValue("StopTimeoutMs") is int stopMs ? TimeSpan.FromMilliseconds(stopMs) : TimeSpan.Zero,
+                WrapperNeedsUpdate = TryGetWrapperVersion(serviceName)?.NeedsUpdate ?? false,
+                Trust = TryGetTrustLevel(exePath),
+                Environment = paramsKey.GetValue("Environment") is string[] envLines ? ServiceUtils.ParseEnvironmentLines(envLines) : null,
                 CreatedAt = createdAt,
                 UpdatedAt = DateTime.Now,
                 AutoStart = true,
@@ -0,0 +1,38 @@
+using System;
+using System.Collections.Concurrent;
+using System.Drawing;
+using System.Drawing.Drawing2D;
+using System.Drawing.Imaging;
+using System.IO;
+
+namespace ServicesApp
+{
+    public static class IconHelper
+    {
+        private static readonly ConcurrentDictionary<string, byte[]> _cache = new(StringComparer.OrdinalIgnoreCase);
+
+        // Returns the first icon of the executable as PNG bytes scaled to size x size
+        public static byte[] GetApplicationIcon(string exePath, int size)
+        {
+            if (!File.Exists(exePath)) throw new FileNotFoundException("Executable not found", exePath);
+
+            return _cache.GetOrAdd($"{Path.GetFullPath(exePath)}|{size}", _ =>
+            {
+                using var icon = Icon.ExtractAssociatedIcon(exePath);
+                if (icon == null) throw new Exception("No icon resource found");
+
+                using var source = icon.ToBitmap();
+                using var scaled = new Bitmap(size, size);
+                using (var g = Graphics.FromImage(scaled))
+                {
+                    g.InterpolationMode = InterpolationMode.HighQualityBicubic;
+                    g.DrawImage(source, 0, 0, size, size);
+                }
+
+                using var ms = new MemoryStream();
+                scaled.Save(ms, ImageFormat.Png);
+                return ms.ToArray();
+            });
+        }
+    }
+}
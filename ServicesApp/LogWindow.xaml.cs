@@ -86,7 +86,7 @@ namespace ServicesApp
                     return;
                 }
 
-                using var fs = new FileStream(_currentLogPath, FileMode.Open, FileAccess.Read, FileShare.ReadWrite);
+                using var fs = new FileStream(_currentLogPath, FileMode.Open, FileAccess.Read, FileShare.ReadWrite | FileShare.Delete);
 
                 // If file shrunk, it was truncated/rotated
                 if (fs.Length < _lastPosition)
@@ -5,6 +5,7 @@ using Microsoft.UI.Xaml.Data;
 using Microsoft.UI;
 using Services.Core.Services;
 using Services.Core.Models;
+using System.Collections.Generic;
 using System.Collections.ObjectModel;
 using System;
 using System.Linq;
@@ -50,9 +51,14 @@ namespace ServicesApp
             _appWindow.Changed += OnAppWindowChanged;
 
             _serviceManager = new WindowsServiceManager();
+            _serviceManager.StartBackgroundTasks();
             _serviceManager.ServiceUpdated += OnServiceUpdated;
+            _serviceManager.ServicesUpdated += OnServicesUpdated;
+            _serviceManager.ServicesReloaded += OnServicesReloaded;
+            _serviceManager.ServiceWarning += OnServiceWarning;
             _envManager = new EnvironmentManager();
             _logManager = new LogManager();
+            _logManager.LogTailError += OnServiceWarning;
 
             this.Activated += OnWindowActivated;
 
@@ -145,8 +151,12 @@ namespace ServicesApp
             if (_serviceManager != null)
             {
                 _serviceManager.ServiceUpdated -= OnServiceUpdated;
+                _serviceManager.ServicesUpdated -= OnServicesUpdated;
+                _serviceManager.ServicesReloaded -= OnServicesReloaded;
+                _serviceManager.ServiceWarning -= OnServiceWarning;
                 _serviceManager.Dispose();
             }
+            _logManager.LogTailError -= OnServiceWarning;
             
             TrayIcon?.Dispose();
             this.Closed -= OnWindowClosed;
@@ -259,11 +269,31 @@ namespace ServicesApp
                 {
                     existing.Status = service.Status;
                     existing.Pid = service.Pid;
+                    existing.HandleLeakWarning = service.HandleLeakWarning;
+                    existing.DailyStartCount = service.DailyStartCount;
+                    existing.DebugMode = service.DebugMode;
+                    existing.IconPath = service.IconPath;
                     existing.UpdatedAt = service.UpdatedAt;
                 }
             });
         }
 
+        private void OnServicesUpdated(object? sender, List<Service> services)
+        {
+            foreach (var service in services)
+                OnServiceUpdated(sender, service);
+        }
+
+        private void OnServicesReloaded(object? sender, EventArgs e)
+        {
+            this.DispatcherQueue.TryEnqueue(() => LoadServices());
+        }
+
+        private void OnServiceWarning(object? sender, ServiceWarningEventArgs e)
+        {
+            this.DispatcherQueue.TryEnqueue(() => UpdateStatus($"[{e.ServiceId}] {e.Message}"));
+        }
+
         private async void LoadServices(bool silent = false)
         {
             if (_isLoadServicesRunning) return;
@@ -297,6 +327,7 @@ namespace ServicesApp
                     }
                 }
 
+                UpdateTrayToolTip();
                 if (!silent) UpdateStatus($"已加载 {list.Count} 个服务。");
             }
             catch (Exception ex)
@@ -309,12 +340,53 @@ namespace ServicesApp
             }
         }
 
+        private void UpdateTrayToolTip()
+        {
+            if (TrayIcon == null) return;
+            try
+            {
+                TrayIcon.ToolTipText = $"ServicesApp - {_serviceManager.GetAutoStartServices().Count} 个服务开机自启";
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Failed to update tray tooltip: {ex.Message}");
+            }
+        }
+
         private async void OnRefreshClick(object sender, RoutedEventArgs e)
         {
             UpdateStatus("正在刷新服务列表...");
-            await _serviceManager.InitializeAsync();
-            LoadServices();
-            UpdateStatus($"已加载 {Services.Count} 个服务。");
+            // ServicesReloaded refreshes the list and reports the new count
+            await _serviceManager.ReloadServiceListAsync();
+        }
+
+        private string? _sortField;
+        private bool _sortAscending = true;
+
+        private void OnSortHeaderTapped(object sender, TappedRoutedEventArgs e)
+        {
+            if (sender is not FrameworkElement header || header.Tag is not string field) return;
+
+            _sortAscending = _sortField != field || !_sortAscending;
+            _sortField = field;
+
+            try
+            {
+                var sorted = _serviceManager.SortServiceList(field, _sortAscending);
+                int target = 0;
+                foreach (var item in sorted)
+                {
+                    var current = Services.FirstOrDefault(s => s.Id == item.Id);
+                    if (current == null) continue;
+                    int oldIndex = Services.IndexOf(current);
+                    if (oldIndex != target) Services.Move(oldIndex, target);
+                    target++;
+                }
+            }
+            catch (Exception ex)
+            {
+                UpdateStatus($"排序失败: {ex.Message}");
+            }
         }
 
         private void UpdateStatus(string message)
@@ -394,6 +466,311 @@ namespace ServicesApp
             }
         }
 
+        private const int AllLogsTailLines = 50;
+
+        // Read failures are reported through LogTailError and leave that service's section empty
+        private async void OnAllLogsClick(object sender, RoutedEventArgs e)
+        {
+            var services = Services.ToList();
+            if (services.Count == 0) return;
+
+            UpdateStatus("正在读取日志...");
+            var logs = await _logManager.GetAllServiceLogsAsync(services.Select(s => s.Id), AllLogsTailLines);
+
+            var stack = new StackPanel { Spacing = 12 };
+            foreach (var service in services)
+            {
+                stack.Children.Add(new TextBlock { Text = service.Name, Style = (Style)Application.Current.Resources["BodyStrongTextBlockStyle"] });
+                var lines = logs.TryGetValue(service.Id, out var tail) ? tail : new List<string>();
+                stack.Children.Add(new TextBlock
+                {
+                    Text = lines.Count > 0 ? string.Join("\n", lines) : "无日志",
+                    TextWrapping = TextWrapping.Wrap,
+                    IsTextSelectionEnabled = true,
+                    FontFamily = new FontFamily("Consolas"),
+                    FontSize = 12
+                });
+            }
+            UpdateStatus("日志读取完成。");
+
+            var dialog = new ContentDialog
+            {
+                Title = $"全部日志 (每个服务最后 {AllLogsTailLines} 行)",
+                Content = new ScrollViewer { Content = stack, VerticalScrollBarVisibility = ScrollBarVisibility.Auto },
+                CloseButtonText = "关闭",
+                XamlRoot = this.Content.XamlRoot
+            };
+            await dialog.ShowAsync();
+        }
+
+        private async void OnDetailsClick(object sender, RoutedEventArgs e)
+        {
+            if (sender is not Button btn || btn.Tag is not string id) return;
+
+            ServiceDetails details;
+            try
+            {
+                details = await _serviceManager.GetServiceDetailsAsync(id);
+            }
+            catch (Exception ex)
+            {
+                await ShowDialog("错误", $"获取服务详情失败: {ex.Message}");
+                return;
+            }
+
+            var grid = new Grid { RowSpacing = 8, ColumnSpacing = 16 };
+            grid.ColumnDefinitions.Add(new ColumnDefinition { Width = GridLength.Auto });
+            grid.ColumnDefinitions.Add(new ColumnDefinition { Width = new GridLength(1, GridUnitType.Star) });
+
+            AddDetailRow(grid, "服务 ID", details.Service.Id);
+            AddDetailRow(grid, "状态", details.Service.Status);
+            AddDetailRow(grid, "启动类型", details.SCMStartType);
+            AddDetailRow(grid, "运行账户", details.SCMRunAsUser);
+            AddDetailRow(grid, "命令行", _serviceManager.GetServiceCommandLine(id));
+            AddDetailRow(grid, "服务路径", details.SCMBinaryPath);
+            AddDetailRow(grid, "依赖服务", details.SCMDependencies.Count > 0 ? string.Join(", ", details.SCMDependencies) : "无");
+            if (details.Service.Timing != null)
+            {
+                var timing = details.Service.Timing;
+                AddDetailRow(grid, "启动耗时", $"{timing.StartLatencyMs} ms (平均 {timing.AvgStartLatencyMs} ms, 最大 {timing.MaxStartLatencyMs} ms)");
+                AddDetailRow(grid, "停止耗时", $"{timing.StopLatencyMs} ms");
+            }
+            if (!string.IsNullOrEmpty(details.Service.LastRestartReason))
+            {
+                AddDetailRow(grid, "上次重启原因", $"{details.Service.LastRestartReason} ({details.Service.LastRestartInitiator})");
+            }
+            if (details.SCMFailureActions != null)
+            {
+                AddDetailRow(grid, "失败操作", string.Join(" / ", details.SCMFailureActions.Actions.Select(a => $"{a.Type} ({a.Delay.TotalSeconds:0}s)")));
+            }
+            if (details.BinaryInfo != null)
+            {
+                AddDetailRow(grid, "文件版本", details.BinaryInfo.FileVersion ?? "未知");
+                AddDetailRow(grid, "发布者", details.BinaryInfo.CompanyName ?? "未知");
+            }
+            if (details.ProcessMetrics != null)
+            {
+                AddDetailRow(grid, "进程 PID", details.ProcessMetrics.Pid.ToString());
+                AddDetailRow(grid, "内存占用", $"{details.ProcessMetrics.WorkingSetMB:F1} MB");
+                AddDetailRow(grid, "句柄数", details.ProcessMetrics.HandleCount.ToString());
+                AddDetailRow(grid, "线程数", details.ProcessMetrics.ThreadCount.ToString());
+            }
+            try
+            {
+                var rules = await Task.Run(() => _serviceManager.GetServiceFirewallRules(id));
+                AddDetailRow(grid, "防火墙规则", rules.Count > 0
+                    ? string.Join("\n", rules.Select(r => $"{r.Name}: {r.Direction} {r.Protocol} {r.LocalPorts} {r.Action}"))
+                    : "无");
+            }
+            catch (Exception ex)
+            {
+                AddDetailRow(grid, "防火墙规则", $"读取失败: {ex.Message}");
+            }
+
+            var dialog = new ContentDialog
+            {
+                Title = details.Service.Name,
+                Content = new ScrollViewer { Content = grid, VerticalScrollBarVisibility = ScrollBarVisibility.Auto },
+                CloseButtonText = "关闭",
+                XamlRoot = this.Content.XamlRoot
+            };
+            await dialog.ShowAsync();
+        }
+
+        private async void OnResourceLimitsClick(object sender, RoutedEventArgs e)
+        {
+            if (sender is not Button btn || btn.Tag is not string id) return;
+
+            ServiceResourceLimits limits;
+            try
+            {
+                limits = _serviceManager.GetServiceResourceLimits(id);
+            }
+            catch (Exception ex)
+            {
+                await ShowDialog("错误", $"获取资源限制失败: {ex.Message}");
+                return;
+            }
+
+            NumberBox CreateBox(string header, double value) => new NumberBox
+            {
+                Header = header,
+                Value = value,
+                Minimum = 0,
+                SpinButtonPlacementMode = NumberBoxSpinButtonPlacementMode.Inline
+            };
+
+            var memoryBox = CreateBox("内存上限 (MB, 0 为不限制)", limits.MemoryLimitMB);
+            // The mask is 64 bits wide, so only the first processor group can be selected
+            var cpuBoxes = Enumerable.Range(0, Math.Min(Environment.ProcessorCount, 64))
+                .Select(i => new CheckBox { Content = $"CPU {i}", MinWidth = 90, IsChecked = ((limits.AffinityMask >> i) & 1) != 0 })
+                .ToList();
+            var cpuGrid = new VariableSizedWrapGrid { Orientation = Orientation.Horizontal, MaximumRowsOrColumns = 4 };
+            foreach (var box in cpuBoxes)
+            {
+                cpuGrid.Children.Add(box);
+            }
+            var affinityPanel = new StackPanel { Spacing = 4 };
+            affinityPanel.Children.Add(new TextBlock { Text = "CPU 亲和性 (全不选为不限制)" });
+            affinityPanel.Children.Add(cpuGrid);
+            var handleBox = CreateBox("句柄数告警阈值 (0 为关闭)", limits.HandleThreshold);
+            var pageFaultBox = CreateBox("页面错误率告警阈值 (次/秒, 0 为关闭)", limits.PageFaultThreshold);
+            var maxRestartsBox = CreateBox("最大重启次数", limits.MaxRestarts);
+            var cooldownBox = CreateBox("重启计数重置间隔 (秒)", limits.RestartCooldownSeconds);
+            var graceBox = CreateBox("停止等待时间 (秒)", limits.StopGracePeriodSeconds);
+            var logSizeBox = CreateBox("单个日志文件上限 (MB, 0 为不限制)", limits.LogMaxSizeMB);
+
+            var stack = new StackPanel { Spacing = 10 };
+            foreach (var control in new FrameworkElement[] { memoryBox, affinityPanel, handleBox, pageFaultBox, maxRestartsBox, cooldownBox, graceBox, logSizeBox })
+            {
+                stack.Children.Add(control);
+            }
+            stack.Children.Add(new TextBlock { Text = "注: 除 CPU 亲和性外，修改将在服务重启后生效。", FontSize = 12, Opacity = 0.6 });
+
+            var dialog = new ContentDialog
+            {
+                Title = "资源限制",
+                Content = new ScrollViewer { Content = stack, VerticalScrollBarVisibility = ScrollBarVisibility.Auto },
+                PrimaryButtonText = "保存",
+                CloseButtonText = "取消",
+                XamlRoot = this.Content.XamlRoot
+            };
+
+            if (await dialog.ShowAsync() != ContentDialogResult.Primary) return;
+
+            try
+            {
+                ulong affinity = 0;
+                for (int i = 0; i < cpuBoxes.Count; i++)
+                {
+                    if (cpuBoxes[i].IsChecked == true) affinity |= 1UL << i;
+                }
+
+                _serviceManager.SetServiceResourceLimits(id, new ServiceResourceLimits
+                {
+                    MemoryLimitMB = (ulong)memoryBox.Value,
+                    AffinityMask = affinity,
+                    HandleThreshold = (uint)handleBox.Value,
+                    PageFaultThreshold = (uint)pageFaultBox.Value,
+                    MaxRestarts = (int)maxRestartsBox.Value,
+                    RestartCooldownSeconds = (int)cooldownBox.Value,
+                    StopGracePeriodSeconds = (int)graceBox.Value,
+                    LogMaxSizeMB = (int)logSizeBox.Value
+                });
+                UpdateStatus("资源限制已保存。");
+            }
+            catch (Exception ex)
+            {
+                await ShowDialog("错误", $"保存资源限制失败: {ex.Message}");
+            }
+        }
+
+        private async void OnCustomControlsClick(object sender, RoutedEventArgs e)
+        {
+            if (sender is not Button btn || btn.Tag is not string id) return;
+
+            Dictionary<int, string> controls;
+            try
+            {
+                controls = _serviceManager.GetServiceCustomControls(id);
+            }
+            catch (Exception ex)
+            {
+                await ShowDialog("错误", $"读取自定义控制失败: {ex.Message}");
+                return;
+            }
+
+            var dialog = new ContentDialog
+            {
+                Title = "自定义控制",
+                PrimaryButtonText = "保存",
+                CloseButtonText = "关闭",
+                XamlRoot = this.Content.XamlRoot
+            };
+
+            var listPanel = new StackPanel { Spacing = 6 };
+            void RenderControls()
+            {
+                listPanel.Children.Clear();
+                if (controls.Count == 0) listPanel.Children.Add(new TextBlock { Text = "尚未定义控制码。", Opacity = 0.6 });
+
+                foreach (var (code, name) in controls.OrderBy(c => c.Key))
+                {
+                    var row = new StackPanel { Orientation = Orientation.Horizontal, Spacing = 8 };
+                    var sendBtn = new Button { Content = "发送" };
+                    sendBtn.Click += (s, args) =>
+                    {
+                        try
+                        {
+                            _serviceManager.SendCustomControlCode(id, code, name);
+                            UpdateStatus($"已发送控制码 {code} ({name})。");
+                        }
+                        catch (Exception ex)
+                        {
+                            UpdateStatus($"发送控制码失败: {ex.Message}");
+                        }
+                    };
+                    var removeBtn = new Button { Content = "移除" };
+                    removeBtn.Click += (s, args) =>
+                    {
+                        controls.Remove(code);
+                        RenderControls();
+                    };
+                    row.Children.Add(new TextBlock { Text = $"{code}  {name}", VerticalAlignment = VerticalAlignment.Center, Width = 220 });
+                    row.Children.Add(sendBtn);
+                    row.Children.Add(removeBtn);
+                    listPanel.Children.Add(row);
+                }
+            }
+            RenderControls();
+
+            var codeBox = new NumberBox { Header = "控制码 (128-255)", Minimum = 128, Maximum = 255, Value = 128, SpinButtonPlacementMode = NumberBoxSpinButtonPlacementMode.Inline };
+            var nameBox = new TextBox { Header = "名称", PlaceholderText = "重新加载配置" };
+            var addBtn = new Button { Content = "添加" };
+            addBtn.Click += (s, args) =>
+            {
+                if (double.IsNaN(codeBox.Value) || string.IsNullOrWhiteSpace(nameBox.Text)) return;
+                controls[(int)codeBox.Value] = nameBox.Text.Trim();
+                nameBox.Text = "";
+                RenderControls();
+            };
+
+            var stack = new StackPanel { Spacing = 10 };
+            stack.Children.Add(listPanel);
+            stack.Children.Add(new MenuFlyoutSeparator());
+            stack.Children.Add(codeBox);
+            stack.Children.Add(nameBox);
+            stack.Children.Add(addBtn);
+            dialog.Content = stack;
+
+            if (await dialog.ShowAsync() != ContentDialogResult.Primary) return;
+
+            try
+            {
+                _serviceManager.SetServiceCustomControls(id, controls);
+                UpdateStatus("自定义控制已保存。");
+            }
+            catch (Exception ex)
+            {
+                await ShowDialog("错误", $"保存自定义控制失败: {ex.Message}");
+            }
+        }
+
+        private static void AddDetailRow(Grid grid, string label, string? value)
+        {
+            int row = grid.RowDefinitions.Count;
+            grid.RowDefinitions.Add(new RowDefinition { Height = GridLength.Auto });
+
+            var labelBlock = new TextBlock { Text = label, Opacity = 0.6 };
+            var valueBlock = new TextBlock { Text = value ?? "", TextWrapping = TextWrapping.Wrap, IsTextSelectionEnabled = true, FontFamily = new FontFamily("Consolas") };
+            Grid.SetRow(labelBlock, row);
+            Grid.SetRow(valueBlock, row);
+            Grid.SetColumn(valueBlock, 1);
+
+            grid.Children.Add(labelBlock);
+            grid.Children.Add(valueBlock);
+        }
+
         private void ShowLogViewer(string serviceId, string displayName)
         {
             var logWindow = new LogWindow(serviceId, displayName, _logManager);
@@ -408,6 +785,8 @@ namespace ServicesApp
         private TextBox? _addSvcWorkDirBox;
         private ComboBox? _addSvcStartupBox;
         private CheckBox? _addSvcAutoRestartCheck;
+        private NumberBox? _addSvcStartTimeoutBox;
+        private NumberBox? _addSvcStopTimeoutBox;
 
         private async void OnAddServiceClick(object sender, RoutedEventArgs e)
         {
@@ -465,7 +844,14 @@ namespace ServicesApp
 
                 var browseBtn = new Button { Content = "📂 选择程序", HorizontalAlignment = HorizontalAlignment.Right };
                 Grid.SetColumn(browseBtn, 1); Grid.SetRow(browseBtn, 4);
-                
+
+                // Start Timeout | Stop Timeout
+                grid.RowDefinitions.Add(new RowDefinition { Height = GridLength.Auto });
+                _addSvcStartTimeoutBox = new NumberBox { Header = "启动超时 (秒, 0 为默认 30 秒)", Minimum = 0, Maximum = 3600, SpinButtonPlacementMode = NumberBoxSpinButtonPlacementMode.Inline };
+                Grid.SetColumn(_addSvcStartTimeoutBox, 0); Grid.SetRow(_addSvcStartTimeoutBox, 5);
+                _addSvcStopTimeoutBox = new NumberBox { Header = "停止超时 (秒, 0 为默认 30 秒)", Minimum = 0, Maximum = 3600, SpinButtonPlacementMode = NumberBoxSpinButtonPlacementMode.Inline };
+                Grid.SetColumn(_addSvcStopTimeoutBox, 1); Grid.SetRow(_addSvcStopTimeoutBox, 5);
+
                 // Add children
                 grid.Children.Add(_addSvcNameBox);
                 grid.Children.Add(_addSvcStartupBox);
@@ -474,8 +860,17 @@ namespace ServicesApp
                 grid.Children.Add(_addSvcWorkDirBox);
                 grid.Children.Add(_addSvcAutoRestartCheck);
                 grid.Children.Add(browseBtn);
+                grid.Children.Add(_addSvcStartTimeoutBox);
+                grid.Children.Add(_addSvcStopTimeoutBox);
 
                 // Events
+                _addSvcNameBox.TextChanged += (s, args) =>
+                {
+                    var name = _addSvcNameBox.Text.Trim();
+                    bool duplicate = name.Length > 0 && Services.Any(svc => string.Equals(svc.Name, name, StringComparison.OrdinalIgnoreCase));
+                    _addSvcNameBox.Description = duplicate ? "⚠ 已存在同名服务" : null;
+                };
+
                 browseBtn.Click += (s, args) =>
                 {
                     var hwnd = WinRT.Interop.WindowNative.GetWindowHandle(this);
@@ -507,6 +902,8 @@ namespace ServicesApp
             _addSvcWorkDirBox!.Text = "";
             _addSvcStartupBox!.SelectedIndex = 0;
             _addSvcAutoRestartCheck!.IsChecked = false;
+            _addSvcStartTimeoutBox!.Value = 0;
+            _addSvcStopTimeoutBox!.Value = 0;
             _addServiceDialog.XamlRoot = this.Content.XamlRoot; // Ensure XamlRoot is current
 
             var result = await _addServiceDialog.ShowAsync();
@@ -527,7 +924,9 @@ namespace ServicesApp
                         Args = _addSvcArgsBox.Text,
                         WorkingDir = _addSvcWorkDirBox.Text,
                         AutoRestart = _addSvcAutoRestartCheck.IsChecked ?? false,
-                        StartupType = (ServiceStartupType)(_addSvcStartupBox.SelectedIndex + 2)
+                        StartupType = (ServiceStartupType)(_addSvcStartupBox.SelectedIndex + 2),
+                        StartTimeout = TimeSpan.FromSeconds(double.IsNaN(_addSvcStartTimeoutBox.Value) ? 0 : _addSvcStartTimeoutBox.Value),
+                        StopTimeout = TimeSpan.FromSeconds(double.IsNaN(_addSvcStopTimeoutBox.Value) ? 0 : _addSvcStopTimeoutBox.Value)
                     };
                     await _serviceManager.CreateServiceAsync(config);
                     LoadServices();
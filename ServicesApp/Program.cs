@@ -4,6 +4,7 @@ using System;
 using System.Threading;
 using System.Diagnostics;
 using System.Runtime.InteropServices;
+using System.Text;
 using System.ServiceProcess;
 using Services.Core.Services;
 
@@ -31,6 +32,12 @@ namespace ServicesApp
                 return;
             }
 
+            if (args.Length >= 1 && args[0] == "--report")
+            {
+                PrintReport();
+                return;
+            }
+
             const string mutexName = "Global\\Services_App_SingleInstance_Mutex";
             using var mutex = new Mutex(true, mutexName, out bool createdNew);
 
@@ -62,6 +69,24 @@ namespace ServicesApp
             });
         }
 
+        // WinExe has no console of its own, so write to the one of the calling shell
+        private static void PrintReport()
+        {
+            AttachConsole(ATTACH_PARENT_PROCESS);
+            using var manager = new WindowsServiceManager();
+            manager.InitializeAsync().GetAwaiter().GetResult();
+            var report = manager.GetManagedServicesReportAsync().GetAwaiter().GetResult();
+
+            using var stdout = Console.OpenStandardOutput();
+            stdout.Write(report);
+            stdout.Write(Encoding.UTF8.GetBytes(Environment.NewLine));
+        }
+
+        private const int ATTACH_PARENT_PROCESS = -1;
+
+        [DllImport("kernel32.dll", SetLastError = true)]
+        private static extern bool AttachConsole(int dwProcessId);
+
         [DllImport("user32.dll")]
         private static extern bool SetForegroundWindow(IntPtr hWnd);
 
@@ -1,6 +1,8 @@
 using System;
+using System.IO;
 using Microsoft.UI.Xaml.Data;
 using Microsoft.UI.Xaml.Media;
+using Microsoft.UI.Xaml.Media.Imaging;
 using Microsoft.UI;
 using Microsoft.UI.Xaml;
 
@@ -18,6 +20,9 @@ namespace ServicesApp
                     "已停止" => new SolidColorBrush(Colors.Gray),
                     "启动中" => new SolidColorBrush(Colors.Orange),
                     "停止中" => new SolidColorBrush(Colors.Orange),
+                    "暂停中" => new SolidColorBrush(Colors.Orange),
+                    "继续中" => new SolidColorBrush(Colors.Orange),
+                    "已暂停" => new SolidColorBrush(Colors.Goldenrod),
                     _ => new SolidColorBrush(Colors.Red)
                 };
             }
@@ -63,4 +68,36 @@ namespace ServicesApp
             throw new NotImplementedException();
         }
     }
-}
\ No newline at end of file
+
+    public class ServiceIconConverter : IValueConverter
+    {
+        private static readonly string[] ImageExtensions = { ".png", ".jpg", ".jpeg", ".bmp" };
+
+        public object? Convert(object value, Type targetType, object parameter, string language)
+        {
+            if (value is not string path || string.IsNullOrEmpty(path)) return null;
+
+            try
+            {
+                if (Array.IndexOf(ImageExtensions, Path.GetExtension(path).ToLowerInvariant()) >= 0)
+                    return new BitmapImage(new Uri(Path.GetFullPath(path)));
+
+                // Executables, DLLs and .ico files
+                var png = IconHelper.GetApplicationIcon(path, 32);
+                var bitmap = new BitmapImage();
+                bitmap.SetSource(new MemoryStream(png).AsRandomAccessStream());
+                return bitmap;
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Icon extraction failed for {path}: {ex.Message}");
+                return null;
+            }
+        }
+
+        public object ConvertBack(object value, Type targetType, object parameter, string language)
+        {
+            throw new NotImplementedException();
+        }
+    }
+}